@@ -0,0 +1,101 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"github.com/dhlanshan/otp/enum"
+	"runtime"
+	"sync"
+)
+
+// BatchItem a code to verify with VerifyBatch
+type BatchItem struct {
+	Cmd      *CreateOtpCmd // The configuration of the code, ignored when Verifier is set
+	Verifier *Verifier     // An existing verifier of the code
+	PassCode string        // The code to verify
+	Request  Request       // The counter (HOTP) or time (TOTP) and PIN of the code
+}
+
+// VerifyBatch verify items on at most workers goroutines, GOMAXPROCS when workers is not positive.
+// Items sharing a Cmd pointer share one Verifier. The results are in the order of items; when ctx
+// is cancelled the items not yet verified report ctx.Err() and it is also returned.
+func VerifyBatch(ctx context.Context, items []BatchItem, workers int) ([]VerifyResult, error) {
+	results := make([]VerifyResult, len(items))
+	if len(items) == 0 {
+		return results, ctx.Err()
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(items))
+
+	verifiers := make(map[*CreateOtpCmd]*batchVerifier)
+	for _, item := range items {
+		if item.Verifier == nil && item.Cmd != nil && verifiers[item.Cmd] == nil {
+			verifiers[item.Cmd] = &batchVerifier{}
+		}
+	}
+
+	done := make([]bool, len(items))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				results[i] = verifyItem(items[i], verifiers)
+				done[i] = true
+			}
+		}()
+	}
+
+send:
+	for i := range items {
+		select {
+		case <-ctx.Done():
+			break send
+		case jobs <- i:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	err := ctx.Err()
+	if err != nil {
+		for i := range results {
+			if !done[i] {
+				results[i] = VerifyResult{Reason: enum.ReasonError, Err: err}
+			}
+		}
+	}
+
+	return results, err
+}
+
+// batchVerifier a verifier built on first use by one of the workers
+type batchVerifier struct {
+	once sync.Once
+	v    *Verifier
+	err  error
+}
+
+func verifyItem(item BatchItem, verifiers map[*CreateOtpCmd]*batchVerifier) VerifyResult {
+	v := item.Verifier
+	if v == nil {
+		if item.Cmd == nil {
+			return VerifyResult{Reason: enum.ReasonError, Err: errors.New("batch item has neither Cmd nor Verifier")}
+		}
+		bv := verifiers[item.Cmd]
+		bv.once.Do(func() { bv.v, bv.err = NewVerifier(item.Cmd) })
+		if bv.err != nil {
+			return VerifyResult{Reason: enum.ReasonError, Err: bv.err}
+		}
+		v = bv.v
+	}
+
+	return v.VerifyWith(item.PassCode, item.Request)
+}
@@ -0,0 +1,56 @@
+package otp
+
+import (
+	"context"
+	"errors"
+	"github.com/dhlanshan/otp/enum"
+	"testing"
+	"time"
+)
+
+func TestVerifyBatch(t *testing.T) {
+	hCmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890"}
+	tCmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8}
+	// RFC 4226 Appendix D
+	hotpCodes := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+
+	var items []BatchItem
+	for i, code := range hotpCodes {
+		items = append(items, BatchItem{Cmd: hCmd, PassCode: code, Request: Request{Counter: uint64(i)}})
+	}
+	items = append(items,
+		BatchItem{Cmd: tCmd, PassCode: "94287082", Request: Request{Time: time.Unix(59, 0)}},
+		BatchItem{Cmd: tCmd, PassCode: "94287083", Request: Request{Time: time.Unix(59, 0)}},
+		BatchItem{Cmd: &CreateOtpCmd{OtpType: "sotp"}, PassCode: "000000"},
+		BatchItem{PassCode: "000000"},
+	)
+
+	results, err := VerifyBatch(context.Background(), items, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range hotpCodes {
+		if !results[i].Valid || results[i].Counter != uint64(i) {
+			t.Errorf("item %d = %+v", i, results[i])
+		}
+	}
+	n := len(hotpCodes)
+	if !results[n].Valid || results[n+1].Reason != enum.ReasonMismatch {
+		t.Errorf("totp items = %+v, %+v", results[n], results[n+1])
+	}
+	if !errors.Is(results[n+2].Err, ErrUnsupportedOtpType) || results[n+3].Reason != enum.ReasonError {
+		t.Errorf("invalid items = %+v, %+v", results[n+2], results[n+3])
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = VerifyBatch(ctx, items, 2)
+	if !errors.Is(err, context.Canceled) || len(results) != len(items) {
+		t.Fatalf("cancelled batch = %v, %d results", err, len(results))
+	}
+	for i, res := range results {
+		if res.Valid || res.Err == nil {
+			t.Errorf("cancelled item %d = %+v", i, res)
+		}
+	}
+}
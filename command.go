@@ -1,9 +1,16 @@
 package otp
 
 import (
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/drift"
 	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/hotp"
 	"github.com/dhlanshan/otp/internal/abstract"
-	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/sealed"
+	"github.com/dhlanshan/otp/totp"
 )
 
 type TypeEnum string
@@ -15,27 +22,157 @@ const (
 
 // CreateOtpCmd OTP参数
 type CreateOtpCmd struct {
-	Issuer      string             // 发证机构/公司的名称
-	AccountName string             // 用户帐户名称（如电子邮件地址
-	OtpType     TypeEnum           // otp类型
-	Period      uint               // TOTP哈希有效的秒数。默认为30秒
-	Skew        uint               // 允许的当前时间之前或之后的时段。值为1时，最多允许指定时间两侧的Period。默认为0
-	SecretSize  uint               // 生成的秘钥的大小。默认为20字节。当秘钥需要随机生成时使用该字段
-	Secret      string             // 存储的秘钥。默认为随机生成的SecretSize秘钥
-	EncSecret   string             // 编码后的秘钥
-	Digits      int                // 密码位数
-	Algorithm   enum.AlgorithmEnum // 用于HMAC的算法。默认为SHA1
-	Pattern     enum.PatternEnum   // 模式
-	Host        string             // host
+	Issuer         string             // 发证机构/公司的名称
+	AccountName    string             // 用户帐户名称（如电子邮件地址
+	OtpType        TypeEnum           // otp类型
+	Period         uint               // TOTP哈希有效的秒数。默认为30秒
+	Skew           uint               // 允许的当前时间之前或之后的时段。值为1时，最多允许指定时间两侧的Period。默认为0
+	SecretSize     uint               // 生成的秘钥的大小。默认为20字节。当秘钥需要随机生成时使用该字段
+	Secret         string             // 存储的秘钥。默认为随机生成的SecretSize秘钥
+	EncSecret      string             // 编码后的秘钥
+	SealedSecret   *sealed.Secret     // 加密存储的秘钥，使用KeyProvider解密。优先于Secret和EncSecret
+	KeyProvider    sealed.KeyProvider `json:"-"` // 用于解开SealedSecret数据密钥的密钥提供者
+	AssociatedData []byte             // SealedSecret的关联数据，如记录ID，必须与加密时一致
+	Digits         int                // 密码位数
+	Algorithm      enum.AlgorithmEnum // 用于HMAC的算法。默认为SHA1
+	Pattern        enum.PatternEnum   // 模式
+	Host           string             // host
+	Counter        uint64             // HOTP的初始计数器，写入密钥URI。默认为0
+	LookAhead      uint               // HOTP校验时允许向后查找的计数器数量。默认为0
+	Clock          abstract.Clock     `json:"-"` // TOTP使用的时钟。默认为系统时钟
+	ReplayStore    replay.Store       `json:"-"` // 记录已使用的动态码以防止重放。为nil时不启用
+	DriftStore     drift.Store        `json:"-"` // 记录每个账户的TOTP时钟漂移。为nil时不启用
+	MaxDrift       uint               // DriftStore记录的最大漂移步数。默认为5
+	Registry       *pattern.Registry  `json:"-"` // 实例使用的模式注册表。默认为pattern.Default()
 }
 
 type Aop struct {
 	PatternName enum.PatternEnum // 模式名
 	Pattern     abstract.Pattern
+	Override    bool // 是否允许覆盖内置模式
 }
 
-func AddOtpPattern(ps []Aop) {
+// AddOtpPattern 将模式注册到默认注册表。内置模式只有在Override为true时才会被覆盖
+func AddOtpPattern(ps []Aop) error {
+	var errs []error
 	for _, p := range ps {
-		common.PatternMap[p.PatternName] = p.Pattern
+		var err error
+		if p.Override {
+			err = pattern.Default().Override(p.PatternName, p.Pattern)
+		} else {
+			err = pattern.Default().Register(p.PatternName, p.Pattern)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
 	}
+
+	return errors.Join(errs...)
+}
+
+// Validate 校验参数，不生成秘钥。未设置的字段使用默认值
+func (cmd *CreateOtpCmd) Validate() error {
+	var err error
+	switch cmd.OtpType {
+	case HOTP:
+		h := &hotp.HOtp{}
+		for _, opt := range cmd.hotpOptions() {
+			if err = opt(h); err != nil {
+				return err
+			}
+		}
+	case TOTP:
+		t := &totp.TOtp{}
+		for _, opt := range cmd.totpOptions() {
+			if err = opt(t); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedOtpType, cmd.OtpType)
+	}
+
+	registry := cmd.Registry
+	if registry == nil {
+		registry = pattern.Default()
+	}
+	if _, ok := registry.Lookup(cmd.Pattern); cmd.Pattern != "" && !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownPattern, cmd.Pattern)
+	}
+
+	return nil
+}
+
+// hotpOptions 将参数转换为hotp.New的选项，零值表示使用默认值
+func (cmd *CreateOtpCmd) hotpOptions() []hotp.Option {
+	opts := []hotp.Option{
+		hotp.WithIssuer(cmd.Issuer),
+		hotp.WithAccountName(cmd.AccountName),
+		hotp.WithAlgorithm(cmd.Algorithm),
+		hotp.WithHost(cmd.Host),
+		hotp.WithCounter(cmd.Counter),
+		hotp.WithLookAhead(cmd.LookAhead),
+		hotp.WithReplayStore(cmd.ReplayStore),
+	}
+	switch {
+	case cmd.SealedSecret != nil:
+		opts = append(opts, hotp.WithSealedSecret(cmd.SealedSecret, cmd.KeyProvider, cmd.AssociatedData))
+	case cmd.EncSecret != "":
+		opts = append(opts, hotp.WithEncSecret(cmd.EncSecret))
+	case cmd.Secret != "":
+		opts = append(opts, hotp.WithSecret([]byte(cmd.Secret)))
+	case cmd.SecretSize != 0:
+		opts = append(opts, hotp.WithRandomSecret(cmd.SecretSize, nil))
+	}
+	if cmd.Digits != 0 {
+		opts = append(opts, hotp.WithDigits(enum.DigitEnum(cmd.Digits)))
+	}
+	if cmd.Pattern != "" {
+		opts = append(opts, hotp.WithPattern(cmd.Pattern))
+	}
+	if cmd.Registry != nil {
+		opts = append(opts, hotp.WithRegistry(cmd.Registry))
+	}
+
+	return opts
+}
+
+// totpOptions 将参数转换为totp.New的选项，零值表示使用默认值
+func (cmd *CreateOtpCmd) totpOptions() []totp.Option {
+	opts := []totp.Option{
+		totp.WithIssuer(cmd.Issuer),
+		totp.WithAccountName(cmd.AccountName),
+		totp.WithAlgorithm(cmd.Algorithm),
+		totp.WithHost(cmd.Host),
+		totp.WithSkew(cmd.Skew),
+		totp.WithReplayStore(cmd.ReplayStore),
+		totp.WithDriftStore(cmd.DriftStore, cmd.MaxDrift),
+	}
+	switch {
+	case cmd.SealedSecret != nil:
+		opts = append(opts, totp.WithSealedSecret(cmd.SealedSecret, cmd.KeyProvider, cmd.AssociatedData))
+	case cmd.EncSecret != "":
+		opts = append(opts, totp.WithEncSecret(cmd.EncSecret))
+	case cmd.Secret != "":
+		opts = append(opts, totp.WithSecret([]byte(cmd.Secret)))
+	case cmd.SecretSize != 0:
+		opts = append(opts, totp.WithRandomSecret(cmd.SecretSize, nil))
+	}
+	if cmd.Period != 0 {
+		opts = append(opts, totp.WithPeriod(cmd.Period))
+	}
+	if cmd.Digits != 0 {
+		opts = append(opts, totp.WithDigits(enum.DigitEnum(cmd.Digits)))
+	}
+	if cmd.Pattern != "" {
+		opts = append(opts, totp.WithPattern(cmd.Pattern))
+	}
+	if cmd.Clock != nil {
+		opts = append(opts, totp.WithClock(cmd.Clock))
+	}
+	if cmd.Registry != nil {
+		opts = append(opts, totp.WithRegistry(cmd.Registry))
+	}
+
+	return opts
 }
@@ -0,0 +1,112 @@
+// Package conformance checks the otp implementations against golden vectors. The vectors of
+// RFC 4226, RFC 6238 and Steam Guard are included, custom patterns can ship their own.
+package conformance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/pattern"
+	"io"
+	"time"
+)
+
+// Vector a golden code of a configuration, counter (HOTP) or time (TOTP) and PIN
+type Vector struct {
+	Name      string           `json:"name"`
+	OtpType   otp.TypeEnum     `json:"type"`                // hotp or totp
+	Secret    string           `json:"secret"`              // The raw secret key
+	Algorithm string           `json:"algorithm,omitempty"` // SHA1, SHA256, SHA512 or MD5. Default is SHA1
+	Digits    int              `json:"digits"`
+	Period    uint             `json:"period,omitempty"`  // TOTP only. Default is 30 seconds
+	Pattern   enum.PatternEnum `json:"pattern,omitempty"` // Default is standard
+	Counter   uint64           `json:"counter,omitempty"` // HOTP only
+	Time      int64            `json:"time,omitempty"`    // TOTP only, in unix seconds
+	PIN       string           `json:"pin,omitempty"`
+	Code      string           `json:"code"`
+}
+
+// Cmd the configuration of the vector, looking patterns up in registry (pattern.Default() when nil)
+func (v Vector) Cmd(registry *pattern.Registry) (*otp.CreateOtpCmd, error) {
+	algorithm := enum.AlgorithmSHA1
+	if v.Algorithm != "" {
+		var err error
+		if algorithm, err = enum.ParseAlgorithm(v.Algorithm); err != nil {
+			return nil, err
+		}
+	}
+
+	return &otp.CreateOtpCmd{
+		OtpType:   v.OtpType,
+		Secret:    v.Secret,
+		Algorithm: algorithm,
+		Digits:    v.Digits,
+		Period:    v.Period,
+		Pattern:   v.Pattern,
+		Registry:  registry,
+	}, nil
+}
+
+// Request the counter or time and PIN of the vector
+func (v Vector) Request() otp.Request {
+	req := otp.Request{Counter: v.Counter, PIN: v.PIN}
+	if v.OtpType == otp.TOTP {
+		req.Time = time.Unix(v.Time, 0)
+	}
+
+	return req
+}
+
+// Check generate the code of the vector and verify it with the top-level helpers and a Verifier
+func (v Vector) Check(registry *pattern.Registry) error {
+	cmd, err := v.Cmd(registry)
+	if err != nil {
+		return fmt.Errorf("%s: %w", v.Name, err)
+	}
+	req := v.Request()
+
+	code, err := otp.GenerateCodeWith(cmd, req)
+	if err != nil {
+		return fmt.Errorf("%s: generate: %w", v.Name, err)
+	}
+	if code != v.Code {
+		return fmt.Errorf("%s: generated %q, want %q", v.Name, code, v.Code)
+	}
+	if err = otp.ValidateWithErr(cmd, v.Code, req); err != nil {
+		return fmt.Errorf("%s: validate: %w", v.Name, err)
+	}
+
+	verifier, err := otp.NewVerifier(cmd)
+	if err != nil {
+		return fmt.Errorf("%s: verifier: %w", v.Name, err)
+	}
+	if res := verifier.VerifyWith(v.Code, req); !res.Valid {
+		return fmt.Errorf("%s: verifier rejected the code: %w", v.Name, res.AsError())
+	}
+
+	return nil
+}
+
+// Check all vectors, returning the joined failures
+func Check(vectors []Vector, registry *pattern.Registry) error {
+	var errs []error
+	for _, v := range vectors {
+		if err := v.Check(registry); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Load a JSON array of vectors
+func Load(r io.Reader) ([]Vector, error) {
+	var vectors []Vector
+	if err := json.NewDecoder(r).Decode(&vectors); err != nil {
+		return nil, fmt.Errorf("invalid vectors: %w", err)
+	}
+
+	return vectors, nil
+}
@@ -0,0 +1,76 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/dhlanshan/otp/pattern"
+	"strings"
+	"testing"
+)
+
+func TestRFC4226(t *testing.T) {
+	if len(RFC4226) != 20 {
+		t.Fatalf("%d vectors; want 20", len(RFC4226))
+	}
+	if err := Check(RFC4226, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRFC6238(t *testing.T) {
+	if len(RFC6238) != 36 {
+		t.Fatalf("%d vectors; want 36", len(RFC6238))
+	}
+	if err := Check(RFC6238, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSteam(t *testing.T) {
+	if err := Check(Steam, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	v := RFC4226[0]
+	v.Code = "000000"
+	if err := v.Check(nil); err == nil || !strings.Contains(err.Error(), v.Name) {
+		t.Fatalf("wrong code = %v", err)
+	}
+}
+
+func TestCustomPatternVectors(t *testing.T) {
+	// Golden vectors of a registered pattern, shipped as JSON. 1094287082 is 0x41397EEA
+	const golden = `[
+		{"name": "hex counter 1", "type": "hotp", "secret": "12345678901234567890", "digits": 8, "pattern": "hexcode", "counter": 1, "code": "41397EEA"},
+		{"name": "hex at 59", "type": "totp", "secret": "12345678901234567890", "digits": 6, "pattern": "hexcode", "time": 59, "code": "397EEA"}
+	]`
+	vectors, err := Load(strings.NewReader(golden))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry := pattern.NewRegistry()
+	if err = registry.RegisterAlphabet("hexcode", pattern.Hex, pattern.BigEndian); err != nil {
+		t.Fatal(err)
+	}
+	if err = Check(vectors, registry); err != nil {
+		t.Fatal(err)
+	}
+	if err = Check(vectors, nil); err == nil {
+		t.Fatal("unregistered pattern passed")
+	}
+
+	var buf bytes.Buffer
+	if err = json.NewEncoder(&buf).Encode(All()); err != nil {
+		t.Fatal(err)
+	}
+	all, err := Load(&buf)
+	if err != nil || len(all) != len(All()) {
+		t.Fatalf("round trip = %d vectors, %v", len(all), err)
+	}
+	if err = Check(all, nil); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,98 @@
+package conformance
+
+import (
+	"fmt"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+)
+
+// RFC 4226 Appendix D, HOTP SHA1 for counters 0 to 9. The 8 digit codes are the published
+// truncated values modulo 10^8.
+var RFC4226 = rfc4226()
+
+// RFC 6238 Appendix B, TOTP with a period of 30 seconds. The 6 digit codes are the last six
+// digits of the published 8 digit ones.
+var RFC6238 = rfc6238()
+
+// Steam Guard codes of the RFC 6238 SHA1 seed. Valve publishes no vectors, these were computed
+// with an independent implementation of the algorithm used by the Steam mobile authenticator.
+var Steam = []Vector{
+	{Name: "steam 59", OtpType: otp.TOTP, Secret: sha1Seed, Digits: 5, Pattern: enum.Steam, Time: 59, Code: "PV9M4"},
+	{Name: "steam 1111111109", OtpType: otp.TOTP, Secret: sha1Seed, Digits: 5, Pattern: enum.Steam, Time: 1111111109, Code: "PY4YB"},
+	{Name: "steam 1111111111", OtpType: otp.TOTP, Secret: sha1Seed, Digits: 5, Pattern: enum.Steam, Time: 1111111111, Code: "5PP3V"},
+	{Name: "steam 1234567890", OtpType: otp.TOTP, Secret: sha1Seed, Digits: 5, Pattern: enum.Steam, Time: 1234567890, Code: "VHHQY"},
+	{Name: "steam 2000000000", OtpType: otp.TOTP, Secret: sha1Seed, Digits: 5, Pattern: enum.Steam, Time: 2000000000, Code: "9N776"},
+	{Name: "steam 20000000000", OtpType: otp.TOTP, Secret: sha1Seed, Digits: 5, Pattern: enum.Steam, Time: 20000000000, Code: "R5DMB"},
+}
+
+// All the built-in vectors
+func All() []Vector {
+	all := append([]Vector(nil), RFC4226...)
+	all = append(all, RFC6238...)
+
+	return append(all, Steam...)
+}
+
+const (
+	sha1Seed   = "12345678901234567890"
+	sha256Seed = "12345678901234567890123456789012"
+	sha512Seed = "1234567890123456789012345678901234567890123456789012345678901234"
+)
+
+func rfc4226() []Vector {
+	truncated := []string{"1284755224", "1094287082", "0137359152", "1726969429", "1640338314", "0868254676", "1918287922", "0082162583", "0673399871", "0645520489"}
+
+	var vectors []Vector
+	for counter, value := range truncated {
+		for _, digits := range []int{6, 8} {
+			vectors = append(vectors, Vector{
+				Name:    fmt.Sprintf("rfc4226 counter %d, %d digits", counter, digits),
+				OtpType: otp.HOTP,
+				Secret:  sha1Seed,
+				Digits:  digits,
+				Counter: uint64(counter),
+				Code:    value[len(value)-digits:],
+			})
+		}
+	}
+
+	return vectors
+}
+
+func rfc6238() []Vector {
+	published := []struct {
+		time                 int64
+		sha1, sha256, sha512 string
+	}{
+		{59, "94287082", "46119246", "90693936"},
+		{1111111109, "07081804", "68084774", "25091201"},
+		{1111111111, "14050471", "67062674", "99943326"},
+		{1234567890, "89005924", "91819424", "93441116"},
+		{2000000000, "69279037", "90698825", "38618901"},
+		{20000000000, "65353130", "77737706", "47863826"},
+	}
+
+	var vectors []Vector
+	for _, p := range published {
+		for _, alg := range []struct{ name, seed, code string }{
+			{"SHA1", sha1Seed, p.sha1},
+			{"SHA256", sha256Seed, p.sha256},
+			{"SHA512", sha512Seed, p.sha512},
+		} {
+			for _, digits := range []int{6, 8} {
+				vectors = append(vectors, Vector{
+					Name:      fmt.Sprintf("rfc6238 %s at %d, %d digits", alg.name, p.time, digits),
+					OtpType:   otp.TOTP,
+					Secret:    alg.seed,
+					Algorithm: alg.name,
+					Digits:    digits,
+					Period:    30,
+					Time:      p.time,
+					Code:      alg.code[len(alg.code)-digits:],
+				})
+			}
+		}
+	}
+
+	return vectors
+}
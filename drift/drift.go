@@ -0,0 +1,54 @@
+package drift
+
+import "sync"
+
+// Store persists the observed TOTP clock drift per account, in time steps (RFC 6238 §6)
+type Store interface {
+	// Load returns the last observed drift of key, 0 when unknown
+	Load(key string) (int64, error)
+	// Save records the drift observed by a successful validation
+	Save(key string, drift int64) error
+}
+
+// MemoryStore an in-memory Store
+type MemoryStore struct {
+	mu     sync.RWMutex
+	drifts map[string]int64
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{drifts: map[string]int64{}}
+}
+
+func (m *MemoryStore) Load(key string) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.drifts[key], nil
+}
+
+func (m *MemoryStore) Save(key string, drift int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if drift == 0 {
+		delete(m.drifts, key)
+		return nil
+	}
+	m.drifts[key] = drift
+
+	return nil
+}
+
+// Clamp limit drift to [-limit, limit]
+func Clamp(drift int64, limit uint) int64 {
+	l := int64(limit)
+	if drift > l {
+		return l
+	}
+	if drift < -l {
+		return -l
+	}
+	return drift
+}
@@ -0,0 +1,42 @@
+package drift
+
+import "testing"
+
+func TestClamp(t *testing.T) {
+	tests := []struct {
+		drift int64
+		limit uint
+		want  int64
+	}{
+		{0, 5, 0},
+		{3, 5, 3},
+		{-3, 5, -3},
+		{7, 5, 5},
+		{-7, 5, -5},
+		{2, 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Clamp(tt.drift, tt.limit); got != tt.want {
+			t.Errorf("Clamp(%d, %d) = %d; want %d", tt.drift, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	if d, err := store.Load("alice"); d != 0 || err != nil {
+		t.Fatalf("unknown key = %d, %v", d, err)
+	}
+	if err := store.Save("alice", -2); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := store.Load("alice"); d != -2 {
+		t.Fatalf("drift = %d; want -2", d)
+	}
+	if err := store.Save("alice", 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.drifts) != 0 {
+		t.Fatal("expected a zero drift to drop the record")
+	}
+}
@@ -0,0 +1,78 @@
+// Package enrollment persists OTP enrollments and manages their lifecycle: enroll, confirm,
+// verify, disable, rotate and delete. Secrets are stored sealed by a sealed.KeyProvider.
+package enrollment
+
+import (
+	"errors"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/sealed"
+	"time"
+)
+
+var (
+	ErrNotFound = errors.New("enrollment not found")
+	ErrExists   = errors.New("enrollment already exists")
+	ErrStatus   = errors.New("enrollment status does not allow the operation")
+	ErrExpired  = errors.New("pending enrollment has expired")
+	ErrToken    = errors.New("invalid enrollment token")
+)
+
+// StatusEnum the lifecycle status of an enrollment
+type StatusEnum string
+
+const (
+	StatusPending  StatusEnum = "pending"  // enrolled, waiting for the first valid code
+	StatusActive   StatusEnum = "active"   // confirmed, codes are accepted
+	StatusDisabled StatusEnum = "disabled" // codes are rejected until enabled again
+)
+
+// Enrollment the persisted OTP configuration of an account
+type Enrollment struct {
+	ID          string             `json:"id"`
+	Issuer      string             `json:"issuer"`
+	AccountName string             `json:"accountName"`
+	OtpType     otp.TypeEnum       `json:"otpType"`
+	Digits      enum.DigitEnum     `json:"digits"`
+	Algorithm   enum.AlgorithmEnum `json:"algorithm"`
+	Period      uint               `json:"period,omitempty"`
+	Pattern     enum.PatternEnum   `json:"pattern"`
+	Host        string             `json:"host,omitempty"`
+	Secret      *sealed.Secret     `json:"secret"`
+	NextSecret  *sealed.Secret     `json:"nextSecret,omitempty"` // A rotated secret waiting for confirmation
+	Counter     uint64             `json:"counter"`              // HOTP: the next expected counter. TOTP: the last accepted time step
+	Drift       int64              `json:"drift,omitempty"`      // The observed TOTP clock drift in steps
+	Status      StatusEnum         `json:"status"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	LastUsedAt  time.Time          `json:"lastUsedAt,omitempty"`
+	ExpiresAt   time.Time          `json:"expiresAt,omitempty"` // A pending enrollment is deleted instead of confirmed after this time
+	TokenHash   []byte             `json:"tokenHash,omitempty"` // The SHA-256 of the secret part of the token returned by Start
+}
+
+// DefaultPendingTTL how long a pending enrollment can be confirmed by default
+const DefaultPendingTTL = 10 * time.Minute
+
+// Clone a copy of e, stores hand out copies so records only change through Update
+func (e *Enrollment) Clone() *Enrollment {
+	c := *e
+	return &c
+}
+
+// expired reports whether e is pending past its expiry
+func (e *Enrollment) expired(now time.Time) bool {
+	return e.Status == StatusPending && !e.ExpiresAt.IsZero() && !now.Before(e.ExpiresAt)
+}
+
+// Store persists enrollments by ID
+type Store interface {
+	// Create add e, ErrExists when its ID is taken
+	Create(e *Enrollment) error
+	// Get the enrollment id, ErrNotFound when missing
+	Get(id string) (*Enrollment, error)
+	// Update replace the stored enrollment with the same ID, ErrNotFound when missing
+	Update(e *Enrollment) error
+	// Delete remove the enrollment id, ErrNotFound when missing
+	Delete(id string) error
+	// List all enrollments, ordered by ID
+	List() ([]*Enrollment, error)
+}
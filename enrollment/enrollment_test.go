@@ -0,0 +1,373 @@
+package enrollment
+
+import (
+	"errors"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/hotp"
+	"github.com/dhlanshan/otp/sealed"
+	"github.com/dhlanshan/otp/totp"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const rfcSecret = "12345678901234567890"
+
+func newManager(t *testing.T, store Store) *Manager {
+	t.Helper()
+	kek, err := sealed.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := sealed.NewLocalProvider("k1", map[string][]byte{"k1": kek})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewManager(store, p)
+}
+
+// code the RFC 4226 code of rfcSecret at counter
+func code(t *testing.T, counter uint64) string {
+	t.Helper()
+	h, err := hotp.New(hotp.WithSecret([]byte(rfcSecret)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := h.GenerateCodeForCounter(counter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestStores(t *testing.T) {
+	file, err := NewFileStore(filepath.Join(t.TempDir(), "enrollments.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, s := range map[string]Store{"memory": NewMemoryStore(), "file": file} {
+		e := &Enrollment{ID: "b", AccountName: "alice", Status: StatusPending}
+		if err = s.Create(e); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if err = s.Create(e); !errors.Is(err, ErrExists) {
+			t.Fatalf("%s: duplicate = %v", name, err)
+		}
+		_ = s.Create(&Enrollment{ID: "a"})
+
+		e.Status = StatusActive // stored records are copies
+		got, err := s.Get("b")
+		if err != nil || got.Status != StatusPending {
+			t.Fatalf("%s: Get = %+v, %v", name, got, err)
+		}
+		if err = s.Update(e); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got, _ = s.Get("b"); got.Status != StatusActive {
+			t.Fatalf("%s: updated status = %s", name, got.Status)
+		}
+		if err = s.Update(&Enrollment{ID: "c"}); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: update missing = %v", name, err)
+		}
+		if all, _ := s.List(); len(all) != 2 || all[0].ID != "a" {
+			t.Fatalf("%s: List = %v", name, all)
+		}
+		if err = s.Delete("a"); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if _, err = s.Get("a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: deleted = %v", name, err)
+		}
+	}
+
+	reopened, err := NewFileStore(file.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Get("b"); err != nil || got.AccountName != "alice" || got.Status != StatusActive {
+		t.Fatalf("reopened = %+v, %v", got, err)
+	}
+}
+
+func TestHOTPLifecycle(t *testing.T) {
+	m := newManager(t, NewMemoryStore())
+	m.LookAhead = 2
+
+	e, uri, err := m.Enroll(&otp.CreateOtpCmd{OtpType: otp.HOTP, Issuer: "Example", AccountName: "alice", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != StatusPending || e.Digits != enum.DigitSix || uri == "" {
+		t.Fatalf("enrolled = %+v %s", e, uri)
+	}
+	if _, err = m.Verify(e.ID, code(t, 0), otp.Request{}); !errors.Is(err, ErrStatus) {
+		t.Fatalf("verify pending = %v", err)
+	}
+	if err = m.Confirm(e.ID, otp.Request{}, "000000"); !errors.Is(err, otp.ErrInvalidCode) {
+		t.Fatalf("confirm wrong code = %v", err)
+	}
+	if err = m.Confirm(e.ID, otp.Request{}, code(t, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the counter advances past the matched one, within the look-ahead window
+	res, err := m.Verify(e.ID, code(t, 3), otp.Request{})
+	if err != nil || !res.Valid || res.Counter != 3 {
+		t.Fatalf("Verify = %+v, %v", res, err)
+	}
+	if res, _ = m.Verify(e.ID, code(t, 3), otp.Request{}); res.Valid {
+		t.Fatal("used code accepted again")
+	}
+	got, _ := m.Get(e.ID)
+	if got.Counter != 4 || got.LastUsedAt.IsZero() {
+		t.Fatalf("stored = %+v", got)
+	}
+
+	if err = m.Disable(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Verify(e.ID, code(t, 4), otp.Request{}); !errors.Is(err, ErrStatus) {
+		t.Fatalf("verify disabled = %v", err)
+	}
+	if err = m.Enable(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if found, _ := m.Find("Example", "alice"); len(found) != 1 || found[0].ID != e.ID {
+		t.Fatalf("Find = %v", found)
+	}
+	if err = m.Delete(e.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Verify(e.ID, code(t, 4), otp.Request{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("verify deleted = %v", err)
+	}
+}
+
+func TestTOTPLifecycle(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "enrollments.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := newManager(t, store)
+	clock := totp.NewFakeClock(time.Unix(59, 0))
+	m.Clock, m.Skew = clock, 1
+
+	e, _, err := m.Enroll(&otp.CreateOtpCmd{OtpType: otp.TOTP, AccountName: "bob", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Confirm(e.ID, otp.Request{}, code(t, 1)); err != nil {
+		t.Fatal(err)
+	}
+	// the confirmation code cannot be replayed
+	if res, _ := m.Verify(e.ID, code(t, 1), otp.Request{}); res.Reason != enum.ReasonReplay {
+		t.Fatalf("replayed = %+v", res)
+	}
+
+	// a code one step ahead is accepted and its drift remembered
+	clock.Set(time.Unix(90, 0))
+	res, err := m.Verify(e.ID, code(t, 4), otp.Request{})
+	if err != nil || !res.Valid || res.Drift != 1 {
+		t.Fatalf("Verify = %+v, %v", res, err)
+	}
+	got, _ := m.Get(e.ID)
+	if got.Counter != 4 || got.Drift != 1 {
+		t.Fatalf("stored = %+v", got)
+	}
+
+	// with the drift, step 6 is within reach at step 4
+	clock.Set(time.Unix(120, 0))
+	if res, _ = m.Verify(e.ID, code(t, 6), otp.Request{}); !res.Valid {
+		t.Fatalf("drifted code = %+v", res)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	m := newManager(t, NewMemoryStore())
+	e, _, err := m.Enroll(&otp.CreateOtpCmd{OtpType: otp.HOTP, AccountName: "carol", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = m.Rotate(e.ID); !errors.Is(err, ErrStatus) {
+		t.Fatalf("rotate pending = %v", err)
+	}
+	if err = m.Confirm(e.ID, otp.Request{}, code(t, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, uri, err := m.Rotate(e.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.NextSecret == nil || uri == "" {
+		t.Fatalf("rotated = %+v", rotated)
+	}
+	// the old secret keeps working until the new one is confirmed
+	if res, _ := m.Verify(e.ID, code(t, 1), otp.Request{}); !res.Valid {
+		t.Fatalf("old secret = %+v", res)
+	}
+
+	key, err := otp.ParseKey(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := hotp.New(hotp.WithEncSecret(key.Secret()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := next.GenerateCodeForCounter(0)
+	if err = m.Confirm(e.ID, otp.Request{}, c); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := m.Get(e.ID)
+	if got.NextSecret != nil || got.Counter != 1 || got.Status != StatusActive {
+		t.Fatalf("promoted = %+v", got)
+	}
+	c, _ = next.GenerateCodeForCounter(1)
+	if res, _ := m.Verify(e.ID, c, otp.Request{}); !res.Valid {
+		t.Fatalf("new secret = %+v", res)
+	}
+	if res, _ := m.Verify(e.ID, code(t, 2), otp.Request{}); res.Valid {
+		t.Fatal("old secret accepted after rotation")
+	}
+}
+
+func TestStartActivate(t *testing.T) {
+	m := newManager(t, NewMemoryStore())
+	clock := totp.NewFakeClock(time.Unix(59, 0))
+	m.Clock, m.PendingTTL = clock, time.Minute
+
+	p, err := m.Start(&otp.CreateOtpCmd{OtpType: otp.TOTP, AccountName: "dave", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.URI == "" || p.Enrollment.Status != StatusPending || !p.Enrollment.ExpiresAt.Equal(time.Unix(119, 0)) {
+		t.Fatalf("pending = %+v", p)
+	}
+	if _, err = m.Verify(p.Enrollment.ID, code(t, 1), otp.Request{}); !errors.Is(err, ErrStatus) {
+		t.Fatalf("verify before activation = %v", err)
+	}
+	for _, bad := range []string{"", p.Enrollment.ID, p.Enrollment.ID + ".AAAA", "x" + p.Token, p.Token[:len(p.Token)-2] + "AA"} {
+		if _, err = m.Activate(bad, otp.Request{}, code(t, 1)); !errors.Is(err, ErrToken) {
+			t.Fatalf("Activate(%q) = %v", bad, err)
+		}
+	}
+	if err = m.Confirm(p.Enrollment.ID, otp.Request{}, code(t, 1)); !errors.Is(err, ErrToken) {
+		t.Fatalf("Confirm without the token = %v", err)
+	}
+	if got, _ := m.Get(p.Enrollment.ID); got.Status != StatusPending {
+		t.Fatalf("status after Confirm = %s", got.Status)
+	}
+	if _, err = m.Activate(p.Token, otp.Request{}, "000000"); !errors.Is(err, otp.ErrInvalidCode) {
+		t.Fatalf("wrong code = %v", err)
+	}
+
+	e, err := m.Activate(p.Token, otp.Request{}, code(t, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Status != StatusActive || e.TokenHash != nil || !e.ExpiresAt.IsZero() {
+		t.Fatalf("activated = %+v", e)
+	}
+	if _, err = m.Activate(p.Token, otp.Request{}, code(t, 1)); !errors.Is(err, ErrToken) {
+		t.Fatalf("token reused = %v", err)
+	}
+	clock.Advance(30 * time.Second)
+	if res, _ := m.Verify(e.ID, code(t, 2), otp.Request{}); !res.Valid {
+		t.Fatalf("Verify after activation = %+v", res)
+	}
+}
+
+func TestPendingExpiry(t *testing.T) {
+	m := newManager(t, NewMemoryStore())
+	clock := totp.NewFakeClock(time.Unix(1000, 0))
+	m.Clock = clock
+
+	p, err := m.Start(&otp.CreateOtpCmd{OtpType: otp.HOTP, AccountName: "erin", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, _, err := m.Enroll(&otp.CreateOtpCmd{OtpType: otp.HOTP, AccountName: "frank", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(DefaultPendingTTL)
+	if _, err = m.Activate(p.Token, otp.Request{}, code(t, 0)); !errors.Is(err, ErrExpired) {
+		t.Fatalf("expired activation = %v", err)
+	}
+	if _, err = m.Get(p.Enrollment.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired enrollment kept = %v", err)
+	}
+	if n, err := m.PurgeExpired(); n != 1 || err != nil {
+		t.Fatalf("PurgeExpired = %d, %v", n, err)
+	}
+	if _, err = m.Get(e.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("purged enrollment kept = %v", err)
+	}
+}
+
+func TestConsecutiveCodes(t *testing.T) {
+	m := newManager(t, NewMemoryStore())
+	m.ConsecutiveCodes, m.LookAhead = true, 2
+
+	p, err := m.Start(&otp.CreateOtpCmd{OtpType: otp.HOTP, AccountName: "grace", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Activate(p.Token, otp.Request{}, code(t, 0)); !errors.Is(err, otp.ErrInvalidParameter) {
+		t.Fatalf("single code = %v", err)
+	}
+	if _, err = m.Activate(p.Token, otp.Request{}, code(t, 0), code(t, 2)); !errors.Is(err, otp.ErrInvalidCode) {
+		t.Fatalf("non consecutive codes = %v", err)
+	}
+	e, err := m.Activate(p.Token, otp.Request{}, code(t, 1), code(t, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Counter != 3 {
+		t.Fatalf("counter = %d", e.Counter)
+	}
+
+	// TOTP: the second code is the current one, the first belongs to the previous step
+	clock := totp.NewFakeClock(time.Unix(95, 0))
+	m.Clock = clock
+	p, err = m.Start(&otp.CreateOtpCmd{OtpType: otp.TOTP, AccountName: "grace", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.Activate(p.Token, otp.Request{}, code(t, 1), code(t, 3)); !errors.Is(err, otp.ErrInvalidCode) {
+		t.Fatalf("skipped step = %v", err)
+	}
+	if e, err = m.Activate(p.Token, otp.Request{}, code(t, 2), code(t, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := m.Verify(e.ID, code(t, 3), otp.Request{}); res.Reason != enum.ReasonReplay {
+		t.Fatalf("replayed activation code = %+v", res)
+	}
+}
+
+func TestSecretBinding(t *testing.T) {
+	m := newManager(t, NewMemoryStore())
+	alice, _, err := m.Enroll(&otp.CreateOtpCmd{OtpType: otp.HOTP, AccountName: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mallory, _, err := m.Enroll(&otp.CreateOtpCmd{OtpType: otp.HOTP, AccountName: "mallory", Secret: rfcSecret})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a sealed secret copied into another record does not open there
+	alice.Secret = mallory.Secret
+	if err = m.Store.Update(alice); err != nil {
+		t.Fatal(err)
+	}
+	if err = m.Confirm(alice.ID, otp.Request{}, code(t, 0)); !errors.Is(err, otp.ErrSecretDecode) {
+		t.Fatalf("copied secret = %v", err)
+	}
+	if err = m.Confirm(mallory.ID, otp.Request{}, code(t, 0)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,129 @@
+package enrollment
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore a Store persisted as a JSON file, rewritten atomically after every change
+type FileStore struct {
+	mu      sync.RWMutex
+	path    string
+	records map[string]*Enrollment
+}
+
+// NewFileStore open the store at path, creating it on the first write if it does not exist
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{path: path, records: map[string]*Enrollment{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &f.records); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// Path the file backing the store
+func (f *FileStore) Path() string {
+	return f.path
+}
+
+func (f *FileStore) Create(e *Enrollment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := create(f.records, e); err != nil {
+		return err
+	}
+	if err := f.save(); err != nil {
+		delete(f.records, e.ID)
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStore) Get(id string) (*Enrollment, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return get(f.records, id)
+}
+
+func (f *FileStore) Update(e *Enrollment) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old, ok := f.records[e.ID]
+	if err := update(f.records, e); err != nil {
+		return err
+	}
+	if err := f.save(); err != nil {
+		if ok {
+			f.records[e.ID] = old
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStore) Delete(id string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	old := f.records[id]
+	if err := remove(f.records, id); err != nil {
+		return err
+	}
+	if err := f.save(); err != nil {
+		f.records[id] = old
+		return err
+	}
+
+	return nil
+}
+
+func (f *FileStore) List() ([]*Enrollment, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	return list(f.records), nil
+}
+
+// save write the records to a temporary file and rename it over the store
+func (f *FileStore) save() error {
+	data, err := json.Marshal(f.records)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0o600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,363 @@
+package enrollment
+
+import (
+	"fmt"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/hotp"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/sealed"
+	"github.com/dhlanshan/otp/totp"
+	"github.com/segmentio/ksuid"
+	"sync"
+	"time"
+)
+
+// Manager runs the enrollment lifecycle over a Store. Operations are serialized, so two
+// concurrent verifications of one enrollment cannot both accept the same code.
+type Manager struct {
+	Store     Store              // The enrollments
+	Provider  sealed.KeyProvider // Seals and opens the secrets
+	LookAhead uint               // The number of HOTP counters after the expected one accepted. Default is 0
+	Skew      uint               // The TOTP periods accepted on either side of the current time. Default is 0
+	MaxDrift  uint               // The largest TOTP drift in steps remembered per enrollment. Default is 5
+	Clock     totp.Clock         // The time source. Defaults to the system clock
+	Registry  *pattern.Registry  // The patterns available to the enrollments. Defaults to pattern.Default()
+
+	PendingTTL       time.Duration // How long a pending enrollment can be confirmed. Default is DefaultPendingTTL
+	ConsecutiveCodes bool          // Confirmation requires two consecutive codes instead of one
+
+	mu sync.Mutex
+}
+
+// NewManager a Manager keeping enrollments in store with secrets sealed by provider
+func NewManager(store Store, provider sealed.KeyProvider) *Manager {
+	return &Manager{Store: store, Provider: provider}
+}
+
+// Enroll create a pending enrollment for the key described by cmd, returning it with its key uri.
+// The secret of cmd is used when set, otherwise a random one is generated. The enrollment
+// expires unless it is confirmed within PendingTTL.
+func (m *Manager) Enroll(cmd *otp.CreateOtpCmd) (*Enrollment, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, uri, err := m.enroll(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+	if err = m.Store.Create(e); err != nil {
+		return nil, "", err
+	}
+
+	return e, uri, nil
+}
+
+// enroll build the pending enrollment of cmd without storing it
+func (m *Manager) enroll(cmd *otp.CreateOtpCmd) (*Enrollment, string, error) {
+	obj, err := otp.NewOtpInstance(cmd)
+	if err != nil {
+		return nil, "", err
+	}
+	now := m.now()
+	e := &Enrollment{ID: ksuid.New().String(), OtpType: cmd.OtpType, Status: StatusPending, CreatedAt: now, ExpiresAt: now.Add(m.pendingTTL())}
+	secret, err := e.configure(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	if e.Secret, err = sealed.Seal(m.Provider, secret, e.associatedData()); err != nil {
+		return nil, "", err
+	}
+	uri, err := obj.GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return e, uri, nil
+}
+
+// Confirm activate a pending enrollment, or promote the secret created by Rotate, once codes
+// verify: one code, or two consecutive ones when ConsecutiveCodes is set. The error wraps
+// otp.ErrInvalidCode or the reason the code was rejected, and ErrExpired after PendingTTL.
+// Enrollments begun by Start are refused with ErrToken, they are confirmed by Activate.
+func (m *Manager) Confirm(id string, req otp.Request, codes ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.Store.Get(id)
+	if err != nil {
+		return err
+	}
+	if e.Status == StatusPending && e.TokenHash != nil {
+		// enrollments begun by Start are only confirmed with their token
+		return ErrToken
+	}
+
+	return m.confirm(e, req, codes)
+}
+
+func (m *Manager) confirm(e *Enrollment, req otp.Request, codes []string) error {
+	if want := m.codeCount(); len(codes) != want {
+		return fmt.Errorf("%w: %d codes, want %d", otp.ErrInvalidParameter, len(codes), want)
+	}
+	var s *sealed.Secret
+	switch {
+	case e.Status == StatusPending:
+		if e.expired(m.now()) {
+			if err := m.Store.Delete(e.ID); err != nil {
+				return err
+			}
+			return ErrExpired
+		}
+		s = e.Secret
+	case e.Status == StatusActive && e.NextSecret != nil:
+		e.Counter, e.Drift = 0, 0
+		s = e.NextSecret
+	default:
+		return fmt.Errorf("%w: %s is %s", ErrStatus, e.ID, e.Status)
+	}
+	obj, err := m.instance(e, s)
+	if err != nil {
+		return err
+	}
+	if err = m.verifyCodes(e, obj, codes, req).AsError(); err != nil {
+		return err
+	}
+	e.Secret, e.NextSecret, e.Status, e.LastUsedAt = s, nil, StatusActive, m.now()
+	e.TokenHash, e.ExpiresAt = nil, time.Time{}
+
+	return m.Store.Update(e)
+}
+
+// Verify check passCode against an active enrollment, advancing its counter and drift when it
+// is accepted. The error reports a missing enrollment, a wrong status or a store failure.
+func (m *Manager) Verify(id, passCode string, req otp.Request) (otp.VerifyResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.Store.Get(id)
+	if err != nil {
+		return otp.VerifyResult{Reason: enum.ReasonError, Err: err}, err
+	}
+	if e.Status != StatusActive {
+		err = fmt.Errorf("%w: %s is %s", ErrStatus, id, e.Status)
+		return otp.VerifyResult{Reason: enum.ReasonError, Err: err}, err
+	}
+	obj, err := m.instance(e, e.Secret)
+	if err != nil {
+		return otp.VerifyResult{Reason: enum.ReasonError, Err: err}, err
+	}
+	res := m.verify(e, obj, passCode, req)
+	if !res.Valid {
+		return res, nil
+	}
+	e.LastUsedAt = m.now()
+	if err = m.Store.Update(e); err != nil {
+		return otp.VerifyResult{Reason: enum.ReasonError, Err: err}, err
+	}
+
+	return res, nil
+}
+
+// Disable reject the codes of an active enrollment until Enable
+func (m *Manager) Disable(id string) error {
+	return m.transition(id, StatusActive, StatusDisabled)
+}
+
+// Enable accept the codes of a disabled enrollment again
+func (m *Manager) Enable(id string) error {
+	return m.transition(id, StatusDisabled, StatusActive)
+}
+
+// Rotate generate a new secret for an active enrollment, returning its key uri. The current
+// secret stays valid until a code of the new one is confirmed with Confirm.
+func (m *Manager) Rotate(id string) (*Enrollment, string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.Store.Get(id)
+	if err != nil {
+		return nil, "", err
+	}
+	if e.Status != StatusActive {
+		return nil, "", fmt.Errorf("%w: %s is %s", ErrStatus, id, e.Status)
+	}
+	obj, err := otp.NewOtpInstance(m.cmd(e, nil))
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := e.configure(obj)
+	if err != nil {
+		return nil, "", err
+	}
+	if e.NextSecret, err = sealed.Seal(m.Provider, secret, e.associatedData()); err != nil {
+		return nil, "", err
+	}
+	uri, err := obj.GenerateKey()
+	if err != nil {
+		return nil, "", err
+	}
+	if err = m.Store.Update(e); err != nil {
+		return nil, "", err
+	}
+
+	return e, uri, nil
+}
+
+// Delete remove the enrollment id
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.Store.Delete(id)
+}
+
+// Get the enrollment id
+func (m *Manager) Get(id string) (*Enrollment, error) {
+	return m.Store.Get(id)
+}
+
+// Find the enrollments of an account
+func (m *Manager) Find(issuer, accountName string) ([]*Enrollment, error) {
+	all, err := m.Store.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []*Enrollment
+	for _, e := range all {
+		if e.Issuer == issuer && e.AccountName == accountName {
+			out = append(out, e)
+		}
+	}
+
+	return out, nil
+}
+
+// transition move the enrollment id from status from to status to
+func (m *Manager) transition(id string, from, to StatusEnum) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, err := m.Store.Get(id)
+	if err != nil {
+		return err
+	}
+	if e.Status != from {
+		return fmt.Errorf("%w: %s is %s", ErrStatus, id, e.Status)
+	}
+	e.Status = to
+
+	return m.Store.Update(e)
+}
+
+// instance the OTP of e with secret s
+func (m *Manager) instance(e *Enrollment, s *sealed.Secret) (abstract.Otp, error) {
+	return otp.NewOtpInstance(m.cmd(e, s))
+}
+
+// verify check passCode with obj, the instance of e, updating e when it is accepted
+func (m *Manager) verify(e *Enrollment, obj abstract.Otp, passCode string, req otp.Request) otp.VerifyResult {
+	if e.OtpType == otp.HOTP {
+		req.Counter = e.Counter
+	}
+	res := obj.VerifyWith(passCode, req)
+	if res.Valid && e.OtpType == otp.HOTP {
+		e.Counter = res.Counter + 1
+	}
+
+	return res
+}
+
+// cmd the configuration of e with secret s, a new random secret when s is nil
+func (m *Manager) cmd(e *Enrollment, s *sealed.Secret) *otp.CreateOtpCmd {
+	cmd := &otp.CreateOtpCmd{
+		Issuer:         e.Issuer,
+		AccountName:    e.AccountName,
+		OtpType:        e.OtpType,
+		Period:         e.Period,
+		Skew:           m.Skew,
+		Digits:         int(e.Digits),
+		Algorithm:      e.Algorithm,
+		Pattern:        e.Pattern,
+		Host:           e.Host,
+		LookAhead:      m.LookAhead,
+		Clock:          m.Clock,
+		MaxDrift:       m.MaxDrift,
+		Registry:       m.Registry,
+		SealedSecret:   s,
+		KeyProvider:    m.Provider,
+		AssociatedData: e.associatedData(),
+	}
+	if e.OtpType == otp.TOTP {
+		st := recordState{e: e}
+		cmd.DriftStore, cmd.ReplayStore = st, st
+	}
+
+	return cmd
+}
+
+func (m *Manager) pendingTTL() time.Duration {
+	if m.PendingTTL == 0 {
+		return DefaultPendingTTL
+	}
+	return m.PendingTTL
+}
+
+func (m *Manager) codeCount() int {
+	if m.ConsecutiveCodes {
+		return 2
+	}
+	return 1
+}
+
+func (m *Manager) now() time.Time {
+	if m.Clock == nil {
+		return time.Now()
+	}
+	return m.Clock.Now()
+}
+
+// associatedData binds the sealed secrets of e to its ID
+func (e *Enrollment) associatedData() []byte {
+	return []byte("enrollment:" + e.ID)
+}
+
+// configure copy the settings of obj into e, returning its secret
+func (e *Enrollment) configure(obj abstract.Otp) ([]byte, error) {
+	switch o := obj.(type) {
+	case *hotp.HOtp:
+		e.Issuer, e.AccountName, e.Host = o.Issuer, o.AccountName, o.Host
+		e.Digits, e.Algorithm, e.Pattern = o.Digits, o.Algorithm, o.Pattern
+		return o.Secret, nil
+	case *totp.TOtp:
+		e.Issuer, e.AccountName, e.Host = o.Issuer, o.AccountName, o.Host
+		e.Digits, e.Algorithm, e.Pattern, e.Period = o.Digits, o.Algorithm, o.Pattern, o.Period
+		return o.Secret, nil
+	}
+
+	return nil, fmt.Errorf("%w: %T", otp.ErrUnsupportedOtpType, obj)
+}
+
+// recordState keeps the TOTP drift and last accepted time step in the enrollment being verified
+type recordState struct {
+	e *Enrollment
+}
+
+func (s recordState) Load(string) (int64, error) {
+	return s.e.Drift, nil
+}
+
+func (s recordState) Save(_ string, drift int64) error {
+	s.e.Drift = drift
+	return nil
+}
+
+func (s recordState) Accept(_ string, counter uint64, _ time.Duration) (bool, error) {
+	if counter <= s.e.Counter {
+		return false, nil
+	}
+	s.e.Counter = counter
+
+	return true, nil
+}
@@ -0,0 +1,98 @@
+package enrollment
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore an in-memory Store
+type MemoryStore struct {
+	mu      sync.RWMutex
+	records map[string]*Enrollment
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{records: map[string]*Enrollment{}}
+}
+
+func (m *MemoryStore) Create(e *Enrollment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return create(m.records, e)
+}
+
+func (m *MemoryStore) Get(id string) (*Enrollment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return get(m.records, id)
+}
+
+func (m *MemoryStore) Update(e *Enrollment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return update(m.records, e)
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return remove(m.records, id)
+}
+
+func (m *MemoryStore) List() ([]*Enrollment, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return list(m.records), nil
+}
+
+func create(records map[string]*Enrollment, e *Enrollment) error {
+	if _, ok := records[e.ID]; ok {
+		return ErrExists
+	}
+	records[e.ID] = e.Clone()
+
+	return nil
+}
+
+func get(records map[string]*Enrollment, id string) (*Enrollment, error) {
+	e, ok := records[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return e.Clone(), nil
+}
+
+func update(records map[string]*Enrollment, e *Enrollment) error {
+	if _, ok := records[e.ID]; !ok {
+		return ErrNotFound
+	}
+	records[e.ID] = e.Clone()
+
+	return nil
+}
+
+func remove(records map[string]*Enrollment, id string) error {
+	if _, ok := records[id]; !ok {
+		return ErrNotFound
+	}
+	delete(records, id)
+
+	return nil
+}
+
+func list(records map[string]*Enrollment) []*Enrollment {
+	out := make([]*Enrollment, 0, len(records))
+	for _, e := range records {
+		out = append(out, e.Clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+
+	return out
+}
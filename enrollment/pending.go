@@ -0,0 +1,157 @@
+package enrollment
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/hotp"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/totp"
+	"io"
+	"strings"
+)
+
+// tokenSize the number of random bytes in an enrollment token
+const tokenSize = 32
+
+// Pending a started enrollment, waiting for the user to scan the key and submit a code
+type Pending struct {
+	Enrollment *Enrollment // The stored enrollment, in StatusPending until Activate
+	Token      string      // Opaque token passed back to Activate, only its hash is stored
+	URI        string      // The key uri shown to the user, usually as a QR code
+}
+
+// Start begin a two-step enrollment: the secret of cmd is stored pending and can only be used
+// once Activate receives the returned token with a valid code before PendingTTL elapses.
+func (m *Manager) Start(cmd *otp.CreateOtpCmd) (*Pending, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, uri, err := m.enroll(cmd)
+	if err != nil {
+		return nil, err
+	}
+	random := make([]byte, tokenSize)
+	if _, err = io.ReadFull(rand.Reader, random); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(random)
+	e.TokenHash = sum[:]
+	if err = m.Store.Create(e); err != nil {
+		return nil, err
+	}
+
+	return &Pending{Enrollment: e, Token: e.ID + "." + base64.RawURLEncoding.EncodeToString(random), URI: uri}, nil
+}
+
+// Activate confirm the pending enrollment of token with codes, see Confirm. It returns
+// ErrToken for an unknown or already used token and ErrExpired after PendingTTL.
+func (m *Manager) Activate(token string, req otp.Request, codes ...string) (*Enrollment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	id, encoded, ok := strings.Cut(token, ".")
+	random, err := base64.RawURLEncoding.DecodeString(encoded)
+	if !ok || err != nil || len(random) != tokenSize {
+		return nil, ErrToken
+	}
+	e, err := m.Store.Get(id)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(random)
+	if e.Status != StatusPending || subtle.ConstantTimeCompare(sum[:], e.TokenHash) != 1 {
+		return nil, ErrToken
+	}
+	if err = m.confirm(e, req, codes); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// PurgeExpired delete the pending enrollments past their expiry, returning how many were deleted
+func (m *Manager) PurgeExpired() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	all, err := m.Store.List()
+	if err != nil {
+		return 0, err
+	}
+	n, now := 0, m.now()
+	for _, e := range all {
+		if !e.expired(now) {
+			continue
+		}
+		if err = m.Store.Delete(e.ID); err != nil && !errors.Is(err, ErrNotFound) {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// verifyCodes check codes with obj, the instance of e. Two codes must be consecutive: for HOTP
+// the first is searched from the expected counter, for TOTP the second is the current one.
+func (m *Manager) verifyCodes(e *Enrollment, obj abstract.Otp, codes []string, req otp.Request) otp.VerifyResult {
+	if len(codes) == 1 {
+		return m.verify(e, obj, codes[0], req)
+	}
+
+	if e.OtpType == otp.TOTP {
+		res := m.verify(e, obj, codes[1], req)
+		if !res.Valid {
+			return res
+		}
+		if res.Counter == 0 {
+			return otp.VerifyResult{Reason: enum.ReasonMismatch}
+		}
+		return matchCounter(obj, codes[0], res.Counter-1, req, res)
+	}
+
+	res := m.verify(e, obj, codes[0], req)
+	if !res.Valid {
+		return res
+	}
+	res = matchCounter(obj, codes[1], res.Counter+1, req, res)
+	if res.Valid {
+		e.Counter = res.Counter + 1
+	}
+
+	return res
+}
+
+// matchCounter res with the counter of passCode when it is the code of obj at counter
+func matchCounter(obj abstract.Otp, passCode string, counter uint64, req otp.Request, res otp.VerifyResult) otp.VerifyResult {
+	var h *hotp.HOtp
+	switch o := obj.(type) {
+	case *hotp.HOtp:
+		h = o
+	case *totp.TOtp:
+		h = &hotp.HOtp{Digits: o.Digits, Algorithm: o.Algorithm, Secret: o.Secret, Pattern: o.Pattern, Registry: o.Registry}
+	default:
+		return otp.VerifyResult{Reason: enum.ReasonError, Err: fmt.Errorf("%w: %T", otp.ErrUnsupportedOtpType, obj)}
+	}
+	ok, err := h.ValidateForCounter(passCode, counter, req.Args()...)
+	if err != nil {
+		return otp.VerifyResult{Reason: enum.ReasonError, Err: err}
+	}
+	if !ok {
+		return otp.VerifyResult{Reason: enum.ReasonMismatch}
+	}
+	if res.Counter < counter {
+		res.Counter, res.Drift = counter, res.Drift+1
+	}
+
+	return res
+}
@@ -7,6 +7,7 @@ import (
 	"crypto/sha512"
 	"fmt"
 	"hash"
+	"strings"
 )
 
 type PatternEnum string
@@ -26,6 +27,11 @@ const (
 	AlgorithmMD5
 )
 
+// Valid reports whether a is a known algorithm.
+func (a AlgorithmEnum) Valid() bool {
+	return a >= AlgorithmSHA1 && a <= AlgorithmMD5
+}
+
 func (a AlgorithmEnum) String() string {
 	switch a {
 	case AlgorithmSHA1:
@@ -40,6 +46,21 @@ func (a AlgorithmEnum) String() string {
 	panic("unreached")
 }
 
+// ParseAlgorithm converts an algorithm name such as "SHA256" into AlgorithmEnum.
+func ParseAlgorithm(name string) (AlgorithmEnum, error) {
+	switch strings.ToUpper(strings.TrimSpace(name)) {
+	case "SHA1":
+		return AlgorithmSHA1, nil
+	case "SHA256":
+		return AlgorithmSHA256, nil
+	case "SHA512":
+		return AlgorithmSHA512, nil
+	case "MD5":
+		return AlgorithmMD5, nil
+	}
+	return 0, fmt.Errorf("unknown algorithm %q", name)
+}
+
 func (a AlgorithmEnum) Hash() hash.Hash {
 	switch a {
 	case AlgorithmSHA1:
@@ -59,11 +80,24 @@ type DigitEnum int
 const (
 	DigitFour  DigitEnum = 4
 	DigitSix   DigitEnum = 6
+	DigitSeven DigitEnum = 7
 	DigitEight DigitEnum = 8
+	DigitTen   DigitEnum = 10
+)
+
+// The supported code lengths, a 31-bit truncated value has at most 10 decimal digits
+const (
+	MinDigits DigitEnum = 1
+	MaxDigits DigitEnum = 10
 )
 
+// Valid reports whether d is between MinDigits and MaxDigits.
+func (d DigitEnum) Valid() bool {
+	return d >= MinDigits && d <= MaxDigits
+}
+
 // Format converts an integer into the zero-filled size for this Digits.
-func (d DigitEnum) Format(in int32) string {
+func (d DigitEnum) Format(in int64) string {
 	f := fmt.Sprintf("%%0%dd", d)
 	return fmt.Sprintf(f, in)
 }
@@ -76,3 +110,17 @@ func (d DigitEnum) Length() int {
 func (d DigitEnum) String() string {
 	return fmt.Sprintf("%d", d)
 }
+
+// ReasonEnum why a dynamic code failed verification
+type ReasonEnum string
+
+const (
+	ReasonNone           ReasonEnum = ""                // verification succeeded
+	ReasonWrongLength    ReasonEnum = "wrong_length"    // the code length does not match the digits
+	ReasonMismatch       ReasonEnum = "mismatch"        // no counter in the window produced the code
+	ReasonMissingCounter ReasonEnum = "missing_counter" // HOTP counter parameter is missing
+	ReasonMissingPIN     ReasonEnum = "missing_pin"     // the pattern requires a PIN
+	ReasonReplay         ReasonEnum = "replay"          // the code has already been used
+	ReasonLocked         ReasonEnum = "locked"          // too many failed attempts, validation is throttled
+	ReasonError          ReasonEnum = "error"           // an internal error occurred, see VerifyResult.Err
+)
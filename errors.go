@@ -0,0 +1,34 @@
+package otp
+
+import (
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/throttle"
+)
+
+// Errors returned by the otp, hotp and totp packages, test them with errors.Is
+var (
+	ErrInvalidCode        = common.ErrInvalidCode        // The code does not match
+	ErrWrongLength        = common.ErrWrongLength        // The code has the wrong number of digits
+	ErrMissingPIN         = common.ErrMissingPIN         // The mobile pattern requires a PIN
+	ErrMissingCounter     = common.ErrMissingCounter     // HOTP requires a counter
+	ErrInvalidParameter   = common.ErrInvalidParameter   // A variadic parameter has an unsupported type or value
+	ErrInvalidDigits      = common.ErrInvalidDigits      // The configured number of digits is not supported
+	ErrInvalidAlgorithm   = common.ErrInvalidAlgorithm   // The HMAC algorithm is unknown
+	ErrInvalidPeriod      = common.ErrInvalidPeriod      // The TOTP period is zero
+	ErrInvalidOption      = common.ErrInvalidOption      // A constructor option has an invalid value
+	ErrUnknownPattern     = common.ErrUnknownPattern     // The pattern is not registered
+	ErrSecretDecode       = common.ErrSecretDecode       // EncSecret is not valid base32
+	ErrSecretGenerate     = common.ErrSecretGenerate     // The random secret could not be generated
+	ErrMissingAccount     = common.ErrMissingAccount     // The key needs an issuer and account name
+	ErrMissingSecret      = common.ErrMissingSecret      // The key has no secret
+	ErrUnsupportedOtpType = common.ErrUnsupportedOtpType // The OTP type is neither HOTP nor TOTP, or does not support the operation
+	ErrResyncFailed       = common.ErrResyncFailed       // No consecutive codes were found in the resync window
+	ErrReplay             = replay.ErrReplay             // The code has already been accepted
+	ErrLocked             = throttle.ErrLocked           // Too many failed attempts
+	ErrBuiltinPattern     = pattern.ErrBuiltin           // Built-in patterns cannot be replaced
+)
+
+// InitError NewHOtp or NewTOtp rejected the configuration, Err holds the cause
+type InitError = common.InitError
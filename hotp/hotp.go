@@ -8,25 +8,37 @@ import (
 	"errors"
 	"fmt"
 	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/abstract"
 	"github.com/dhlanshan/otp/internal/command"
 	"github.com/dhlanshan/otp/internal/common"
 	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/sealed"
 	"io"
+	"math"
 	"net/url"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 type HOtp struct {
-	Issuer      string             // The name of the issuer/company
-	AccountName string             // The user's account name (e.g., email address)
-	SecretSize  uint               // The size of the secret key to generate. Defaults to 20 bytes. Used when the key needs to be randomly generated
-	Secret      []byte             // The raw secret key. Defaults to a randomly generated key of size SecretSize
-	EncSecret   string             // The encoded secret key
-	Digits      enum.DigitEnum     // The number of digits in the OTP
-	Algorithm   enum.AlgorithmEnum // The algorithm used for HMAC. Defaults to SHA1
-	Pattern     enum.PatternEnum   // The OTP generation pattern
-	Rand        io.Reader          // The reader used for generating TOTP keys
-	Host        string             // The host of the key
+	Issuer       string             // The name of the issuer/company
+	AccountName  string             // The user's account name (e.g., email address)
+	SecretSize   uint               // The size of the secret key to generate. Defaults to 20 bytes. Used when the key needs to be randomly generated
+	Secret       []byte             // The raw secret key. Defaults to a randomly generated key of size SecretSize
+	EncSecret    string             // The encoded secret key
+	Digits       enum.DigitEnum     // The number of digits in the OTP
+	Algorithm    enum.AlgorithmEnum // The algorithm used for HMAC. Defaults to SHA1
+	Pattern      enum.PatternEnum   // The OTP generation pattern
+	Rand         io.Reader          // The reader used for generating TOTP keys
+	Host         string             // The host of the key
+	Counter      uint64             // The initial counter written to the key uri. Default is 0
+	LookAhead    uint               // The number of counters after the expected one accepted by Validate. Default is 0
+	ResyncWindow uint               // The number of counters searched by Resync. Default is 100
+	ReplayStore  replay.Store       // Records accepted counters per account to reject reused codes. Disabled when nil
+	Registry     *pattern.Registry  // The patterns available to this instance. Defaults to pattern.Default()
 }
 
 // NewHOtp initializes and returns a new HOtp instance based on the provided CreateOtpCmd configuration.
@@ -42,16 +54,28 @@ func NewHOtp(cmd *command.CreateOtpCmd) (*HOtp, error) {
 		Pattern:     cmd.Pattern,
 		Rand:        rand.Reader,
 		Host:        cmd.Host,
+		Counter:     cmd.Counter,
+		LookAhead:   cmd.LookAhead,
+		ReplayStore: cmd.ReplayStore,
+		Registry:    cmd.Registry,
+	}
+	if cmd.SealedSecret != nil {
+		if err := WithSealedSecret(cmd.SealedSecret, cmd.KeyProvider, cmd.AssociatedData)(hObj); err != nil {
+			return nil, &common.InitError{OtpType: "HOTP", Err: err}
+		}
 	}
 	if err := hObj.Init(); err != nil {
-		return nil, errors.New(fmt.Sprintf("HOTP init failed: %s", err.Error()))
+		return nil, &common.InitError{OtpType: "HOTP", Err: err}
 	}
-	// Load default pattern
-	common.SetDefaultPattern()
-
 	return hObj, nil
 }
 
+// SealSecret encrypt the secret key with a data key wrapped by p, for storage in place of EncSecret.
+// ad binds the sealed secret to its record, see sealed.Seal.
+func (h *HOtp) SealSecret(p sealed.KeyProvider, ad []byte) (*sealed.Secret, error) {
+	return sealed.Seal(p, h.Secret, ad)
+}
+
 func (h *HOtp) Init() error {
 	if h.Issuer == "" {
 		h.Issuer = common.DefaultIssuer
@@ -65,13 +89,25 @@ func (h *HOtp) Init() error {
 	if h.Digits == 0 {
 		h.Digits = enum.DigitSix
 	}
+	if !h.Digits.Valid() {
+		return fmt.Errorf("%w: %d, must be between %d and %d", common.ErrInvalidDigits, h.Digits, enum.MinDigits, enum.MaxDigits)
+	}
 	if h.Rand == nil {
 		h.Rand = rand.Reader
 	}
+	if !h.Algorithm.Valid() {
+		return fmt.Errorf("%w: %d", common.ErrInvalidAlgorithm, h.Algorithm)
+	}
+	if h.Registry == nil {
+		h.Registry = pattern.Default()
+	}
+	if h.ResyncWindow == 0 {
+		h.ResyncWindow = common.DefaultResyncWindow
+	}
 	if h.EncSecret != "" {
 		secret, err := util.DecodeBase32Secret(h.EncSecret)
 		if err != nil {
-			return errors.New("EncSecret key decoding failed")
+			return fmt.Errorf("%w: %w", common.ErrSecretDecode, err)
 		}
 		h.Secret = secret
 		h.SecretSize = uint(len(secret))
@@ -79,7 +115,7 @@ func (h *HOtp) Init() error {
 	if len(h.Secret) == 0 {
 		h.Secret = make([]byte, h.SecretSize)
 		if _, err := h.Rand.Read(h.Secret); err != nil {
-			return errors.New("init Secret failed")
+			return fmt.Errorf("%w: %w", common.ErrSecretGenerate, err)
 		}
 	} else {
 		h.SecretSize = uint(len(h.Secret))
@@ -90,23 +126,33 @@ func (h *HOtp) Init() error {
 	if h.Pattern == "" {
 		h.Pattern = enum.Standard
 	}
+	if _, ok := h.Registry.Lookup(h.Pattern); !ok {
+		return fmt.Errorf("%w: %s", common.ErrUnknownPattern, h.Pattern)
+	}
 	if h.Pattern == enum.Standard {
 		h.Host = "hotp"
 	}
+	if h.Host == "" {
+		h.Host = string(h.Pattern)
+	}
 
 	return nil
 }
 
 func (h *HOtp) GenerateCodeForCounter(counter uint64, pins ...string) (passCode string, err error) {
-	if h.Digits == 0 {
-		return "", errors.New("invalid password digits")
+	if !h.Digits.Valid() {
+		return "", common.ErrInvalidDigits
 	}
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, counter)
 
-	p, ok := common.PatternMap[h.Pattern]
+	registry := h.Registry
+	if registry == nil {
+		registry = pattern.Default()
+	}
+	p, ok := registry.Lookup(h.Pattern)
 	if !ok {
-		return "", errors.New("invalid pattern")
+		return "", fmt.Errorf("%w: %s", common.ErrUnknownPattern, h.Pattern)
 	}
 	buf, err = p.CounterFun(buf, pins...)
 	if err != nil {
@@ -117,14 +163,7 @@ func (h *HOtp) GenerateCodeForCounter(counter uint64, pins ...string) (passCode
 	mac.Write(buf)
 	sum := mac.Sum(nil)
 
-	offset := sum[len(sum)-1] & 0xf
-	if int(offset)+3 >= len(sum) {
-		return "", fmt.Errorf("invalid offset, hashSum length is too short")
-	}
-	value := int64(((int(sum[offset]) & 0x7f) << 24) |
-		((int(sum[offset+1] & 0xff)) << 16) |
-		((int(sum[offset+2] & 0xff)) << 8) |
-		(int(sum[offset+3]) & 0xff))
+	value := util.DynamicTruncate(sum)
 
 	dl := h.Digits.Length()
 	passCode = p.CalculationFun(value, dl, h.Digits)
@@ -132,13 +171,13 @@ func (h *HOtp) GenerateCodeForCounter(counter uint64, pins ...string) (passCode
 	return
 }
 
-func (h *HOtp) ValidateForCounter(passCode string, counter uint64, pin string) (bool, error) {
+func (h *HOtp) ValidateForCounter(passCode string, counter uint64, pins ...string) (bool, error) {
 	passCode = strings.TrimSpace(passCode)
-	if len(passCode) != h.Digits.Length() {
-		return false, errors.New("invalid password digits")
+	if utf8.RuneCountInString(passCode) != h.Digits.Length() {
+		return false, common.ErrWrongLength
 	}
 
-	newPassCode, err := h.GenerateCodeForCounter(counter, pin)
+	newPassCode, err := h.GenerateCodeForCounter(counter, pins...)
 	if err != nil {
 		return false, err
 	}
@@ -153,18 +192,27 @@ func (h *HOtp) ValidateForCounter(passCode string, counter uint64, pin string) (
 // GenerateCode generate dynamic password
 func (h *HOtp) GenerateCode(counters ...any) ([]string, error) {
 	if len(counters) == 0 {
-		return nil, errors.New("counters is empty")
+		return nil, fmt.Errorf("%w: counters is empty", common.ErrMissingCounter)
 	}
 
-	counter, pin := util.ParameterParsing(h.Pattern, counters)
-	if counter == 0 {
-		return nil, errors.New("missing counter parameter")
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return nil, err
 	}
-	if h.Pattern == enum.Mobile && pin == "" {
-		return nil, errors.New("missing pin parameter")
+	if req.Counter == 0 {
+		return nil, common.ErrMissingCounter
 	}
 
-	passCode, err := h.GenerateCodeForCounter(counter, pin)
+	return h.GenerateCodeWith(req)
+}
+
+// GenerateCodeWith generate the dynamic password of req.Counter
+func (h *HOtp) GenerateCodeWith(req abstract.Request) ([]string, error) {
+	if h.Pattern == enum.Mobile && req.PIN == "" {
+		return nil, common.ErrMissingPIN
+	}
+
+	passCode, err := h.GenerateCodeForCounter(req.Counter, req.Args()...)
 	if err != nil {
 		return nil, err
 	}
@@ -174,25 +222,132 @@ func (h *HOtp) GenerateCode(counters ...any) ([]string, error) {
 
 // Validate verify dynamic password
 func (h *HOtp) Validate(passCode string, counters ...any) (bool, error) {
+	res := h.Verify(passCode, counters...)
+
+	return res.Valid, res.Err
+}
+
+// ValidateWith verify dynamic password against req.Counter..req.Counter+LookAhead
+func (h *HOtp) ValidateWith(passCode string, req abstract.Request) (bool, error) {
+	res := h.VerifyWith(passCode, req)
+
+	return res.Valid, res.Err
+}
+
+// Verify verify dynamic password and report the matched counter, drift and failure reason
+func (h *HOtp) Verify(passCode string, counters ...any) abstract.VerifyResult {
 	if len(counters) == 0 {
-		return false, errors.New("counters is empty")
+		return abstract.VerifyResult{Reason: enum.ReasonMissingCounter, Err: fmt.Errorf("%w: counters is empty", common.ErrMissingCounter)}
+	}
+
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return abstract.VerifyResult{Reason: enum.ReasonError, Err: err}
+	}
+	if req.Counter == 0 {
+		return abstract.VerifyResult{Reason: enum.ReasonMissingCounter, Err: common.ErrMissingCounter}
+	}
+
+	return h.VerifyWith(passCode, req)
+}
+
+// VerifyCounter verify the password against counter..counter+LookAhead (RFC 4226 §7.4).
+// Drift is the number of counters the token is ahead of counter.
+func (h *HOtp) VerifyCounter(passCode string, counter uint64, pin string) abstract.VerifyResult {
+	return h.VerifyWith(passCode, abstract.Request{Counter: counter, PIN: pin})
+}
+
+// VerifyWith verify the password against req.Counter..req.Counter+LookAhead (RFC 4226 §7.4).
+// Drift is the number of counters the token is ahead of req.Counter.
+func (h *HOtp) VerifyWith(passCode string, req abstract.Request) abstract.VerifyResult {
+	if h.Pattern == enum.Mobile && req.PIN == "" {
+		return abstract.VerifyResult{Reason: enum.ReasonMissingPIN, Err: common.ErrMissingPIN}
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(passCode)) != h.Digits.Length() {
+		return abstract.VerifyResult{Reason: enum.ReasonWrongLength, Err: common.ErrWrongLength}
+	}
+
+	matched, ok, err := h.search(passCode, req.Counter, h.LookAhead, req.Args())
+	if err != nil {
+		return abstract.VerifyResult{Reason: enum.ReasonError, Err: err}
 	}
+	if !ok {
+		return abstract.VerifyResult{Reason: enum.ReasonMismatch}
+	}
+
+	res := abstract.VerifyResult{Counter: matched, Drift: int64(matched - req.Counter)}
+	if h.ReplayStore != nil {
+		if err = replay.Check(h.ReplayStore, util.StoreKey(h.Issuer, h.AccountName, h.Secret), matched, 0); err != nil {
+			res.Reason, res.Err = enum.ReasonError, err
+			if errors.Is(err, replay.ErrReplay) {
+				res.Reason = enum.ReasonReplay
+			}
+			return res
+		}
+	}
+	res.Valid = true
+
+	return res
+}
+
+// ValidateLookAhead verify the password against counter..counter+LookAhead (RFC 4226 §7.4).
+// The matched counter is returned, the caller should persist matched+1 as the next counter.
+func (h *HOtp) ValidateLookAhead(passCode string, counter uint64, pin string) (uint64, bool, error) {
+	res := h.VerifyCounter(passCode, counter, pin)
 
-	counter, pin := util.ParameterParsing(h.Pattern, counters)
-	if counter == 0 {
-		return false, errors.New("missing counter parameter")
+	return res.Counter, res.Valid, res.Err
+}
+
+// Resync recover a desynchronized token from two consecutive passwords (RFC 4226 §7.4).
+// It searches ResyncWindow counters from counter for code1 followed by code2 and returns the
+// counter matched by code2, the caller should persist matched+1 as the next counter.
+func (h *HOtp) Resync(code1, code2 string, counter uint64, pin string) (uint64, error) {
+	end := counter + uint64(h.ResyncWindow)
+	if end < counter {
+		end = math.MaxUint64
+	}
+	for c := counter; c < end; c++ {
+		ok, err := h.ValidateForCounter(code1, c, pin)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			continue
+		}
+		if ok, err = h.ValidateForCounter(code2, c+1, pin); err != nil {
+			return 0, err
+		}
+		if ok {
+			return c + 1, nil
+		}
 	}
-	if h.Pattern == enum.Mobile && pin == "" {
-		return false, errors.New("missing pin parameter")
+
+	return 0, common.ErrResyncFailed
+}
+
+// search verify the password against counter..counter+window and return the matched counter
+func (h *HOtp) search(passCode string, counter uint64, window uint, args []string) (uint64, bool, error) {
+	for i := uint64(0); i <= uint64(window); i++ {
+		c := counter + i
+		if c < counter {
+			break
+		}
+		ok, err := h.ValidateForCounter(passCode, c, args...)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			return c, true, nil
+		}
 	}
 
-	return h.ValidateForCounter(passCode, counter, pin)
+	return 0, false, nil
 }
 
 // GenerateKey new key
 func (h *HOtp) GenerateKey() (string, error) {
 	if h.Issuer == "" || h.AccountName == "" {
-		return "", errors.New("lacking necessary account information")
+		return "", common.ErrMissingAccount
 	}
 
 	val := url.Values{}
@@ -200,6 +355,11 @@ func (h *HOtp) GenerateKey() (string, error) {
 	val.Set("issuer", h.Issuer)
 	val.Set("algorithm", h.Algorithm.String())
 	val.Set("digits", h.Digits.String())
+	val.Set("counter", strconv.FormatUint(h.Counter, 10))
+	if h.Host != "hotp" {
+		// other hosts name the pattern, the type tells ParseKey the key is counter based
+		val.Set("type", "hotp")
+	}
 
 	u := url.URL{Scheme: "otpauth", Host: h.Host, Path: "/" + h.Issuer + ":" + h.AccountName, RawQuery: util.EncodeQuery(val)}
 
@@ -0,0 +1,55 @@
+package hotp
+
+import (
+	"errors"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/replay"
+	"path/filepath"
+	"testing"
+)
+
+// newHOtp the RFC 4226 test configuration
+func newHOtp(t *testing.T, opts ...Option) *HOtp {
+	t.Helper()
+	obj, err := New(append([]Option{WithSecret([]byte("12345678901234567890"))}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestValidateReplay(t *testing.T) {
+	store, err := replay.NewFileStore(filepath.Join(t.TempDir(), "replay.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := newHOtp(t, WithAccountName("alice"), WithReplayStore(store))
+	if ok, err := obj.Validate("254676", uint64(5)); !ok || err != nil {
+		t.Fatalf("first use = %v, %v", ok, err)
+	}
+	reopened, err := replay.NewFileStore(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj = newHOtp(t, WithAccountName("alice"), WithReplayStore(reopened))
+	if ok, err := obj.Validate("254676", uint64(5)); ok || !errors.Is(err, replay.ErrReplay) {
+		t.Fatalf("reuse after reopening the store = %v, %v; want ErrReplay", ok, err)
+	}
+}
+
+func TestReplaySecrets(t *testing.T) {
+	store := replay.NewMemoryStore()
+	a := newHOtp(t, WithReplayStore(store))
+	b := newHOtp(t, WithSecret([]byte("abcdefghijklmnopqrst")), WithReplayStore(store))
+	codes, err := b.GenerateCodeWith(abstract.Request{Counter: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// both use the default issuer and account name, but not the same secret
+	if ok, _ := a.Validate("969429", uint64(3)); !ok {
+		t.Fatal("expected the code of the first secret to be accepted")
+	}
+	if ok, _ := b.Validate(codes[0], uint64(3)); !ok {
+		t.Fatal("expected the code of the second secret to be accepted")
+	}
+}
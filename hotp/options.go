@@ -0,0 +1,189 @@
+package hotp
+
+import (
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/sealed"
+	"io"
+)
+
+// Option configures an HOtp built by New
+type Option func(h *HOtp) error
+
+// New build and initialize an HOtp, unset fields take the same defaults as NewHOtp
+func New(opts ...Option) (*HOtp, error) {
+	h := &HOtp{}
+	for _, opt := range opts {
+		if err := opt(h); err != nil {
+			return nil, &common.InitError{OtpType: "HOTP", Err: err}
+		}
+	}
+	if err := h.Init(); err != nil {
+		return nil, &common.InitError{OtpType: "HOTP", Err: err}
+	}
+
+	return h, nil
+}
+
+// WithIssuer set the name of the issuer/company
+func WithIssuer(issuer string) Option {
+	return func(h *HOtp) error {
+		h.Issuer = issuer
+		return nil
+	}
+}
+
+// WithAccountName set the user's account name
+func WithAccountName(accountName string) Option {
+	return func(h *HOtp) error {
+		h.AccountName = accountName
+		return nil
+	}
+}
+
+// WithSecret use the raw secret key
+func WithSecret(secret []byte) Option {
+	return func(h *HOtp) error {
+		if len(secret) == 0 {
+			return fmt.Errorf("%w: secret is empty", common.ErrInvalidOption)
+		}
+		h.Secret, h.EncSecret = secret, ""
+		return nil
+	}
+}
+
+// WithEncSecret use the base32 encoded secret key
+func WithEncSecret(encSecret string) Option {
+	return func(h *HOtp) error {
+		secret, err := util.DecodeBase32Secret(encSecret)
+		if err != nil || len(secret) == 0 {
+			return fmt.Errorf("%w: %q", common.ErrSecretDecode, encSecret)
+		}
+		h.Secret, h.EncSecret = secret, encSecret
+		return nil
+	}
+}
+
+// WithSealedSecret use the secret key sealed by s, opened with the key-encryption keys of p and
+// the associated data ad given to sealed.Seal
+func WithSealedSecret(s *sealed.Secret, p sealed.KeyProvider, ad []byte) Option {
+	return func(h *HOtp) error {
+		if s == nil || p == nil {
+			return fmt.Errorf("%w: sealed secret and key provider are required", common.ErrInvalidOption)
+		}
+		secret, err := s.Open(p, ad)
+		if err != nil {
+			return fmt.Errorf("%w: %w", common.ErrSecretDecode, err)
+		}
+		if len(secret) == 0 {
+			return fmt.Errorf("%w: sealed secret is empty", common.ErrSecretDecode)
+		}
+		h.Secret, h.EncSecret = secret, ""
+		return nil
+	}
+}
+
+// WithRandomSecret generate a secret key of size bytes from r, crypto/rand when r is nil
+func WithRandomSecret(size uint, r io.Reader) Option {
+	return func(h *HOtp) error {
+		if size == 0 {
+			return fmt.Errorf("%w: secret size is zero", common.ErrInvalidOption)
+		}
+		h.SecretSize, h.Secret, h.EncSecret = size, nil, ""
+		if r != nil {
+			h.Rand = r
+		}
+		return nil
+	}
+}
+
+// WithDigits set the number of digits, between enum.MinDigits and enum.MaxDigits
+func WithDigits(digits enum.DigitEnum) Option {
+	return func(h *HOtp) error {
+		if !digits.Valid() {
+			return fmt.Errorf("%w: %d, must be between %d and %d", common.ErrInvalidDigits, digits, enum.MinDigits, enum.MaxDigits)
+		}
+		h.Digits = digits
+		return nil
+	}
+}
+
+// WithAlgorithm set the HMAC algorithm
+func WithAlgorithm(algorithm enum.AlgorithmEnum) Option {
+	return func(h *HOtp) error {
+		if !algorithm.Valid() {
+			return fmt.Errorf("%w: %d", common.ErrInvalidAlgorithm, algorithm)
+		}
+		h.Algorithm = algorithm
+		return nil
+	}
+}
+
+// WithPattern set the generation pattern, it must be registered when New initializes the HOtp
+func WithPattern(name enum.PatternEnum) Option {
+	return func(h *HOtp) error {
+		if name == "" {
+			return fmt.Errorf("%w: pattern is empty", common.ErrInvalidOption)
+		}
+		h.Pattern = name
+		return nil
+	}
+}
+
+// WithRegistry look patterns up in r instead of pattern.Default()
+func WithRegistry(r *pattern.Registry) Option {
+	return func(h *HOtp) error {
+		if r == nil {
+			return fmt.Errorf("%w: registry is nil", common.ErrInvalidOption)
+		}
+		h.Registry = r
+		return nil
+	}
+}
+
+// WithHost set the host of the key uri
+func WithHost(host string) Option {
+	return func(h *HOtp) error {
+		h.Host = host
+		return nil
+	}
+}
+
+// WithCounter set the initial counter written to the key uri
+func WithCounter(counter uint64) Option {
+	return func(h *HOtp) error {
+		h.Counter = counter
+		return nil
+	}
+}
+
+// WithLookAhead accept codes up to n counters after the expected one
+func WithLookAhead(n uint) Option {
+	return func(h *HOtp) error {
+		h.LookAhead = n
+		return nil
+	}
+}
+
+// WithResyncWindow set the number of counters searched by Resync
+func WithResyncWindow(n uint) Option {
+	return func(h *HOtp) error {
+		if n == 0 {
+			return fmt.Errorf("%w: resync window is zero", common.ErrInvalidOption)
+		}
+		h.ResyncWindow = n
+		return nil
+	}
+}
+
+// WithReplayStore reject codes whose counter was already accepted
+func WithReplayStore(s replay.Store) Option {
+	return func(h *HOtp) error {
+		h.ReplayStore = s
+		return nil
+	}
+}
@@ -0,0 +1,8 @@
+package abstract
+
+import "time"
+
+// Clock provides the current time for time-based OTP
+type Clock interface {
+	Now() time.Time
+}
@@ -1,10 +1,38 @@
 package abstract
 
+import "github.com/dhlanshan/otp/enum"
+
+// Key an otpauth:// key
 type Key interface {
+	Type() string                  // otp type, hotp or totp
+	Issuer() string                // The name of the issuer/company
+	AccountName() string           // The user's account name
+	Secret() string                // The base32 encoded secret key
+	Period() uint                  // TOTP hash validity duration
+	Digits() enum.DigitEnum        // The number of digits in the OTP
+	Algorithm() enum.AlgorithmEnum // The algorithm used for HMAC
+	Counter() uint64               // The initial HOTP counter
+	Pattern() enum.PatternEnum     // The OTP generation pattern
+	Host() string                  // The host of the key
+	String() string                // The key uri
 }
 
+// TypedOtp generates and verifies dynamic codes from a typed Request.
+// ValidateWith returns false and a nil error for a code that does not match, the error
+// reports why a code could not be checked; VerifyResult.AsError classifies a mismatch.
+type TypedOtp interface {
+	GenerateCodeWith(req Request) ([]string, error)
+	ValidateWith(passCode string, req Request) (bool, error)
+	VerifyWith(passCode string, req Request) VerifyResult
+	GenerateKey() (string, error)
+}
+
+// Otp adds the variadic methods, thin adapters of TypedOtp kept for compatibility.
+// A uint64 or other non-negative integer is the counter, the first string is the PIN,
+// later strings are context data, a time.Time is the TOTP time and a Request is used as is.
 type Otp interface {
+	TypedOtp
 	GenerateCode(counters ...any) ([]string, error)
 	Validate(passCode string, counters ...any) (bool, error)
-	GenerateKey() (string, error)
+	Verify(passCode string, counters ...any) VerifyResult
 }
@@ -0,0 +1,16 @@
+package abstract
+
+import "time"
+
+// Request the typed parameters of generating or verifying a dynamic code
+type Request struct {
+	Counter uint64    // The HOTP counter
+	PIN     string    // The PIN required by patterns such as mobile
+	Time    time.Time // The TOTP time, zero means the current time of the clock
+	Context []string  // Extra data passed to the pattern after the PIN
+}
+
+// Args the arguments passed to Pattern.CounterFun
+func (r Request) Args() []string {
+	return append([]string{r.PIN}, r.Context...)
+}
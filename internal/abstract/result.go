@@ -0,0 +1,27 @@
+package abstract
+
+import (
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/common"
+)
+
+// VerifyResult the outcome of verifying a dynamic code
+type VerifyResult struct {
+	Valid   bool            // Whether the code was accepted
+	Counter uint64          // The counter (HOTP) or time step (TOTP) matched by the code
+	Drift   int64           // The matched counter minus the expected one, in steps
+	Reason  enum.ReasonEnum // Why verification failed, empty when Valid
+	Err     error           // The underlying error, if any
+}
+
+// AsError nil when the code was accepted, otherwise Err or ErrInvalidCode for a plain mismatch
+func (r VerifyResult) AsError() error {
+	switch {
+	case r.Valid:
+		return nil
+	case r.Err != nil:
+		return r.Err
+	default:
+		return common.ErrInvalidCode
+	}
+}
@@ -1,21 +1,36 @@
 package command
 
 import (
+	"github.com/dhlanshan/otp/drift"
 	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/sealed"
 )
 
 // CreateOtpCmd OTP command
 type CreateOtpCmd struct {
-	Issuer      string             // The name of the issuer/company
-	AccountName string             // The user's account name (e.g., email address)
-	OtpType     string             // otp type
-	Period      uint               // TOTP hash validity duration. Default is 30 seconds.
-	Skew        uint               // The allowed time period before or after the current time. When the value is 1, a maximum of two periods on either side of the specified time are allowed. Default is 0
-	SecretSize  uint               // The size of the secret key to generate. Defaults to 20 bytes. Used when the key needs to be randomly generated
-	Secret      string             // The raw secret key. Defaults to a randomly generated key of size SecretSize
-	EncSecret   string             // The encoded secret key
-	Digits      enum.DigitEnum     // The number of digits in the OTP
-	Algorithm   enum.AlgorithmEnum // The algorithm used for HMAC. Defaults to SHA1
-	Pattern     enum.PatternEnum   // The OTP generation pattern
-	Host        string             // The host of the key
+	Issuer         string             // The name of the issuer/company
+	AccountName    string             // The user's account name (e.g., email address)
+	OtpType        string             // otp type
+	Period         uint               // TOTP hash validity duration. Default is 30 seconds.
+	Skew           uint               // The allowed time period before or after the current time. When the value is 1, a maximum of two periods on either side of the specified time are allowed. Default is 0
+	SecretSize     uint               // The size of the secret key to generate. Defaults to 20 bytes. Used when the key needs to be randomly generated
+	Secret         string             // The raw secret key. Defaults to a randomly generated key of size SecretSize
+	EncSecret      string             // The encoded secret key
+	SealedSecret   *sealed.Secret     // The secret key encrypted at rest, opened with KeyProvider. Takes precedence over Secret and EncSecret
+	KeyProvider    sealed.KeyProvider `json:"-"` // Unwraps the data key of SealedSecret
+	AssociatedData []byte             // The associated data SealedSecret was sealed with, e.g. the record ID
+	Digits         enum.DigitEnum     // The number of digits in the OTP
+	Algorithm      enum.AlgorithmEnum // The algorithm used for HMAC. Defaults to SHA1
+	Pattern        enum.PatternEnum   // The OTP generation pattern
+	Host           string             // The host of the key
+	Counter        uint64             // The initial HOTP counter written to the key uri. Default is 0
+	LookAhead      uint               // The number of HOTP counters after the expected one accepted by Validate. Default is 0
+	Clock          abstract.Clock     `json:"-"` // The time source of TOTP. Defaults to the system clock
+	ReplayStore    replay.Store       `json:"-"` // Records accepted codes to reject reuse. Disabled when nil
+	DriftStore     drift.Store        `json:"-"` // Records the observed TOTP clock drift per account. Disabled when nil
+	MaxDrift       uint               // The largest TOTP drift in steps remembered by DriftStore. Default is 5
+	Registry       *pattern.Registry  `json:"-"` // The patterns available to the instance. Defaults to pattern.Default()
 }
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors, re-exported by the otp package
+var (
+	ErrInvalidCode        = errors.New("invalid dynamic code")
+	ErrWrongLength        = errors.New("invalid password digits")
+	ErrMissingPIN         = errors.New("missing pin parameter")
+	ErrMissingCounter     = errors.New("missing counter parameter")
+	ErrInvalidParameter   = errors.New("invalid parameter")
+	ErrInvalidDigits      = errors.New("invalid digits")
+	ErrInvalidAlgorithm   = errors.New("unknown algorithm")
+	ErrInvalidPeriod      = errors.New("invalid period")
+	ErrInvalidOption      = errors.New("invalid option")
+	ErrUnknownPattern     = errors.New("invalid pattern")
+	ErrSecretDecode       = errors.New("EncSecret key decoding failed")
+	ErrSecretGenerate     = errors.New("init Secret failed")
+	ErrMissingAccount     = errors.New("lacking necessary account information")
+	ErrMissingSecret      = errors.New("missing secret")
+	ErrUnsupportedOtpType = errors.New("unsupported OTP type")
+	ErrResyncFailed       = errors.New("resync failed, no consecutive codes found in window")
+)
+
+// InitError an OTP instance could not be initialized from its configuration
+type InitError struct {
+	OtpType string // HOTP or TOTP
+	Err     error
+}
+
+func (e *InitError) Error() string {
+	return fmt.Sprintf("%s init failed: %s", e.OtpType, e.Err.Error())
+}
+
+func (e *InitError) Unwrap() error {
+	return e.Err
+}
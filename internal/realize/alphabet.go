@@ -0,0 +1,64 @@
+package realize
+
+import (
+	"errors"
+	"github.com/dhlanshan/otp/enum"
+)
+
+// OrderEnum the position of the least significant character in an alphabet code
+type OrderEnum int
+
+const (
+	LittleEndian OrderEnum = iota // least significant character first, like steam
+	BigEndian                     // most significant character first, like mobile and decimal codes
+)
+
+// AlphabetPattern encodes the truncated value in base len(Alphabet) using the characters of Alphabet
+type AlphabetPattern struct {
+	Alphabet []rune
+	Order    OrderEnum
+}
+
+// NewAlphabetPattern check that alphabet has at least two distinct characters
+func NewAlphabetPattern(alphabet string, order OrderEnum) (*AlphabetPattern, error) {
+	chars := []rune(alphabet)
+	if len(chars) < 2 {
+		return nil, errors.New("alphabet needs at least two characters")
+	}
+	seen := make(map[rune]bool, len(chars))
+	for _, c := range chars {
+		if seen[c] {
+			return nil, errors.New("alphabet contains duplicate character " + string(c))
+		}
+		seen[c] = true
+	}
+	if order != LittleEndian && order != BigEndian {
+		return nil, errors.New("invalid alphabet order")
+	}
+
+	return &AlphabetPattern{Alphabet: chars, Order: order}, nil
+}
+
+func (ap *AlphabetPattern) CounterFun(buf []byte, str ...string) ([]byte, error) {
+	return buf, nil
+}
+
+func (ap *AlphabetPattern) CalculationFun(value int64, dl int, digits enum.DigitEnum) string {
+	return encodeAlphabet(ap.Alphabet, ap.Order, value, dl)
+}
+
+// encodeAlphabet the dl least significant base len(alphabet) digits of value
+func encodeAlphabet(alphabet []rune, order OrderEnum, value int64, dl int) string {
+	result := make([]rune, dl)
+	sl := int64(len(alphabet))
+	for i := 0; i < dl; i++ {
+		pos := i
+		if order == BigEndian {
+			pos = dl - 1 - i
+		}
+		result[pos] = alphabet[value%sl]
+		value /= sl
+	}
+
+	return string(result)
+}
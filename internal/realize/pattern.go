@@ -6,6 +6,11 @@ import (
 	"math"
 )
 
+var (
+	steamChars  = []rune("23456789BCDFGHJKMNPQRTVWXY")
+	mobileChars = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789")
+)
+
 // StandardPattern hotp|totp
 type StandardPattern struct{}
 
@@ -15,7 +20,7 @@ func (hp *StandardPattern) CounterFun(buf []byte, str ...string) ([]byte, error)
 
 func (hp *StandardPattern) CalculationFun(value int64, dl int, digits enum.DigitEnum) string {
 	mod := value % int64(math.Pow10(dl))
-	return digits.Format(int32(mod))
+	return digits.Format(mod)
 }
 
 // SteamPattern steam
@@ -26,15 +31,7 @@ func (sp *SteamPattern) CounterFun(buf []byte, str ...string) ([]byte, error) {
 }
 
 func (sp *SteamPattern) CalculationFun(value int64, dl int, digits enum.DigitEnum) string {
-	result := ""
-	steamChars := "23456789BCDFGHJKMNPQRTVWXY"
-	sl := int64(len(steamChars))
-	for i := 0; i < dl; i++ {
-		result += string(steamChars[value%sl])
-		value /= sl
-	}
-
-	return result
+	return encodeAlphabet(steamChars, LittleEndian, value, dl)
 }
 
 // MobilePattern mobile
@@ -50,13 +47,5 @@ func (mp *MobilePattern) CounterFun(buf []byte, str ...string) ([]byte, error) {
 }
 
 func (mp *MobilePattern) CalculationFun(value int64, dl int, digits enum.DigitEnum) string {
-	result := ""
-	charSet := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
-	sl := int64(len(charSet))
-	for i := 0; i < dl; i++ {
-		result = string(charSet[value%sl]) + result
-		value /= sl
-	}
-
-	return result
+	return encodeAlphabet(mobileChars, BigEndian, value, dl)
 }
@@ -1,11 +1,22 @@
 package util
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base32"
-	"github.com/dhlanshan/otp/enum"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/common"
+	"hash"
+	"iter"
+	"math"
 	"net/url"
+	"reflect"
 	"sort"
 	"strings"
+	"time"
 )
 
 // DecodeBase32Secret decode Base32 key
@@ -17,6 +28,17 @@ func DecodeBase32Secret(encSecret string) ([]byte, error) {
 	return base32.StdEncoding.DecodeString(encSecret)
 }
 
+// DynamicTruncate extract the 31-bit value of an HMAC result (RFC 4226 §5.3)
+func DynamicTruncate(sum []byte) int64 {
+	offset := sum[len(sum)-1] & 0xf
+	return int64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+}
+
+// FormatDigits the last digits decimal digits of value, zero padded
+func FormatDigits(value int64, digits int) string {
+	return fmt.Sprintf("%0*d", digits, value%int64(math.Pow10(digits)))
+}
+
 // CalculateCounters calculate all counter values within the time offset window.
 func CalculateCounters(baseCounter int64, skew uint) []uint64 {
 	counters := []uint64{uint64(baseCounter)}
@@ -27,6 +49,47 @@ func CalculateCounters(baseCounter int64, skew uint) []uint64 {
 	return counters
 }
 
+// WindowCounters the counters within skew steps of counter+drift, then those within skew
+// steps of counter that the first window missed. The uncorrected window keeps an account
+// whose clock was corrected after its drift was stored from being locked out.
+func WindowCounters(counter, drift int64, skew uint) iter.Seq[uint64] {
+	return func(yield func(uint64) bool) {
+		center, s := counter+drift, int64(skew)
+		for _, base := range [2]int64{center, counter} {
+			for i := int64(0); i <= s; i++ {
+				for j, c := range [2]int64{base + i, base - i} {
+					if i == 0 && j == 1 {
+						break
+					}
+					if base == counter && drift != 0 && c >= center-s && c <= center+s {
+						continue
+					}
+					if !yield(uint64(c)) {
+						return
+					}
+				}
+			}
+			if drift == 0 {
+				return
+			}
+		}
+	}
+}
+
+// AccountKey the key identifying an account in per-account stores
+func AccountKey(issuer, accountName string) string {
+	return issuer + ":" + accountName
+}
+
+// StoreKey the key of a secret in per-account stores, AccountKey followed by a truncated HMAC
+// of the secret. Configurations sharing the default issuer and account name do not share records.
+func StoreKey(issuer, accountName string, secret []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("otp store key"))
+
+	return AccountKey(issuer, accountName) + ":" + hex.EncodeToString(mac.Sum(nil)[:8])
+}
+
 func EncodeQuery(v url.Values) string {
 	if v == nil {
 		return ""
@@ -62,32 +125,71 @@ func NewKeyFromUrl(orig string) (string, error) {
 	return u.String(), nil
 }
 
-func ParameterParsing(pattern enum.PatternEnum, counters ...any) (counter uint64, pin string) {
-	switch pattern {
-	case enum.Mobile:
-		cc := counters[0].([]any)
-		for i, c := range cc {
-			if i >= 2 {
-				continue
+// ParseRequest convert the variadic parameters of the legacy API into a Request
+func ParseRequest(args []any) (abstract.Request, error) {
+	var req abstract.Request
+	pinSet := false
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case nil:
+		case abstract.Request:
+			req, pinSet = v, v.PIN != ""
+		case *abstract.Request:
+			if v != nil {
+				req, pinSet = *v, v.PIN != ""
 			}
-			switch v := c.(type) {
-			case string:
-				if pin == "" {
-					pin = v
-				}
-			case uint64:
-				if counter == 0 {
-					counter = v
-				}
+		case string:
+			if pinSet {
+				req.Context = append(req.Context, v)
+			} else {
+				req.PIN, pinSet = v, true
 			}
+		case []byte:
+			req.Context = append(req.Context, string(v))
+		case time.Time:
+			req.Time = v
+		case uint64:
+			req.Counter = v
+		case uint:
+			req.Counter = uint64(v)
+		case uint32:
+			req.Counter = uint64(v)
+		case int, int64, int32:
+			n := reflect.ValueOf(v).Int()
+			if n < 0 {
+				return req, fmt.Errorf("%w: invalid counter %d", common.ErrInvalidParameter, n)
+			}
+			req.Counter = uint64(n)
+		default:
+			return req, fmt.Errorf("%w: unsupported parameter type %T", common.ErrInvalidParameter, arg)
 		}
-	default:
-		if nested, ok := counters[0].([]any); ok && len(nested) > 0 {
-			if v, ok := nested[0].(uint64); ok && v != 0 {
-				counter = v
+	}
+
+	return req, nil
+}
+
+// PBKDF2 derive a key of keyLen bytes from password (RFC 8018 §5.2)
+func PBKDF2(h func() hash.Hash, password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(h, password)
+	var key []byte
+	var block [4]byte
+	for i := uint32(1); len(key) < keyLen; i++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(block[:], i)
+		prf.Write(block[:])
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for j := 1; j < iterations; j++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for k := range t {
+				t[k] ^= u[k]
 			}
 		}
+		key = append(key, t...)
 	}
 
-	return
+	return key[:keyLen]
 }
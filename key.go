@@ -0,0 +1,340 @@
+package otp
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/qrcode"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Key a parsed otpauth:// key uri
+type Key struct {
+	otpType     TypeEnum
+	issuer      string
+	accountName string
+	secret      string
+	period      uint
+	digits      enum.DigitEnum
+	algorithm   enum.AlgorithmEnum
+	counter     uint64
+	pattern     enum.PatternEnum
+	host        string
+	uri         string
+}
+
+var _ abstract.Key = (*Key)(nil)
+
+// ParseKey parse an otpauth:// key uri, e.g. otpauth://totp/Issuer:alice?secret=...&period=30
+func ParseKey(uri string) (*Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key uri: %w", err)
+	}
+	if u.Scheme != "otpauth" {
+		return nil, fmt.Errorf("invalid key uri: unsupported scheme %q", u.Scheme)
+	}
+
+	k := &Key{host: strings.ToLower(u.Host), uri: u.String()}
+	q := u.Query()
+
+	switch k.host {
+	case "":
+		return nil, errors.New("invalid key uri: missing otp type")
+	case string(HOTP):
+		k.otpType, k.pattern = HOTP, enum.Standard
+	case string(TOTP):
+		k.otpType, k.pattern = TOTP, enum.Standard
+	default:
+		// Other hosts name the pattern, the type parameter tells counter and time based keys apart
+		k.pattern = enum.PatternEnum(k.host)
+		switch t := strings.ToLower(q.Get("type")); t {
+		case "", string(TOTP):
+			k.otpType = TOTP
+		case string(HOTP):
+			k.otpType = HOTP
+		default:
+			return nil, fmt.Errorf("invalid key uri: unknown otp type %q", t)
+		}
+	}
+
+	if err = k.parseLabel(u.Path, q.Get("issuer")); err != nil {
+		return nil, err
+	}
+
+	secret := strings.TrimSpace(q.Get("secret"))
+	if secret == "" {
+		return nil, errors.New("invalid key uri: missing secret")
+	}
+	raw, err := util.DecodeBase32Secret(secret)
+	if err != nil || len(raw) == 0 {
+		return nil, errors.New("invalid key uri: secret is not valid base32")
+	}
+	k.secret = common.B32NoPadding.EncodeToString(raw)
+
+	k.algorithm = enum.AlgorithmSHA1
+	if v := q.Get("algorithm"); v != "" {
+		if k.algorithm, err = enum.ParseAlgorithm(v); err != nil {
+			return nil, fmt.Errorf("invalid key uri: %w", err)
+		}
+	}
+
+	k.digits = enum.DigitSix
+	if k.pattern == enum.Steam {
+		k.digits = 5
+	}
+	if v := q.Get("digits"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || !enum.DigitEnum(d).Valid() {
+			return nil, fmt.Errorf("invalid key uri: invalid digits %q", v)
+		}
+		k.digits = enum.DigitEnum(d)
+	}
+
+	if k.otpType == TOTP {
+		k.period = common.DefaultPeriod
+		if v := q.Get("period"); v != "" {
+			p, err := strconv.ParseUint(v, 10, 32)
+			if err != nil || p == 0 {
+				return nil, fmt.Errorf("invalid key uri: invalid period %q", v)
+			}
+			k.period = uint(p)
+		}
+	}
+
+	if v := q.Get("counter"); v != "" {
+		if k.counter, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid key uri: invalid counter %q", v)
+		}
+	}
+
+	return k, nil
+}
+
+// NewKey build the key of an OTP configuration, counter is the initial HOTP counter.
+// Unlike GenerateKey no defaults are filled in for the issuer, account or secret.
+func NewKey(cmd *CreateOtpCmd, counter uint64) (*Key, error) {
+	k := &Key{
+		otpType:     cmd.OtpType,
+		issuer:      strings.TrimSpace(cmd.Issuer),
+		accountName: strings.TrimSpace(cmd.AccountName),
+		digits:      enum.DigitEnum(cmd.Digits),
+		algorithm:   cmd.Algorithm,
+		pattern:     cmd.Pattern,
+		host:        cmd.Host,
+	}
+	if k.otpType != HOTP && k.otpType != TOTP {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedOtpType, k.otpType)
+	}
+	if k.accountName == "" {
+		return nil, fmt.Errorf("%w: missing account name", ErrMissingAccount)
+	}
+	if !k.algorithm.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidAlgorithm, k.algorithm)
+	}
+
+	raw := []byte(cmd.Secret)
+	if cmd.EncSecret != "" {
+		var err error
+		if raw, err = util.DecodeBase32Secret(cmd.EncSecret); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSecretDecode, err)
+		}
+	}
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("%w: secret is empty", ErrMissingSecret)
+	}
+	k.secret = common.B32NoPadding.EncodeToString(raw)
+
+	if k.pattern == "" {
+		k.pattern = enum.Standard
+	}
+	switch k.pattern {
+	case enum.Standard:
+		k.host = string(k.otpType)
+	case enum.Steam:
+		k.host = string(enum.Steam)
+		if k.digits == 0 {
+			k.digits = 5
+		}
+	default:
+		if k.host == "" {
+			k.host = string(k.pattern)
+		}
+	}
+	if k.digits == 0 {
+		k.digits = enum.DigitSix
+	}
+	if !k.digits.Valid() {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidDigits, k.digits)
+	}
+
+	val := url.Values{}
+	val.Set("secret", k.secret)
+	val.Set("algorithm", k.algorithm.String())
+	val.Set("digits", k.digits.String())
+	if k.issuer != "" {
+		val.Set("issuer", k.issuer)
+	}
+	if k.host != string(HOTP) && k.host != string(TOTP) {
+		val.Set("type", string(k.otpType))
+	}
+	if k.otpType == TOTP {
+		k.period = cmd.Period
+		if k.period == 0 {
+			k.period = common.DefaultPeriod
+		}
+		val.Set("period", strconv.FormatUint(uint64(k.period), 10))
+	} else {
+		k.counter = counter
+		val.Set("counter", strconv.FormatUint(counter, 10))
+	}
+
+	label := k.accountName
+	if k.issuer != "" {
+		label = k.issuer + ":" + k.accountName
+	}
+	u := url.URL{Scheme: "otpauth", Host: k.host, Path: "/" + label, RawQuery: util.EncodeQuery(val)}
+	k.uri = u.String()
+
+	return k, nil
+}
+
+// parseLabel split the "Issuer:Account" label and reconcile it with the issuer parameter
+func (k *Key) parseLabel(path, issuer string) error {
+	label := strings.TrimPrefix(path, "/")
+
+	account := label
+	if i := strings.Index(label, ":"); i >= 0 {
+		k.issuer = strings.TrimSpace(label[:i])
+		account = label[i+1:]
+	}
+	k.accountName = strings.TrimSpace(account)
+	if k.accountName == "" {
+		return errors.New("invalid key uri: missing account name")
+	}
+
+	issuer = strings.TrimSpace(issuer)
+	if k.issuer == "" {
+		k.issuer = issuer
+	} else if issuer != "" && issuer != k.issuer {
+		return fmt.Errorf("invalid key uri: issuer %q does not match label issuer %q", issuer, k.issuer)
+	}
+
+	return nil
+}
+
+// Type otp type, hotp or totp
+func (k *Key) Type() string {
+	return string(k.otpType)
+}
+
+// OtpType otp type
+func (k *Key) OtpType() TypeEnum {
+	return k.otpType
+}
+
+// Issuer the name of the issuer/company
+func (k *Key) Issuer() string {
+	return k.issuer
+}
+
+// AccountName the user's account name
+func (k *Key) AccountName() string {
+	return k.accountName
+}
+
+// Secret the base32 encoded secret key, without padding
+func (k *Key) Secret() string {
+	return k.secret
+}
+
+// Period TOTP hash validity duration, 0 for HOTP
+func (k *Key) Period() uint {
+	return k.period
+}
+
+// Digits the number of digits in the OTP
+func (k *Key) Digits() enum.DigitEnum {
+	return k.digits
+}
+
+// Algorithm the algorithm used for HMAC
+func (k *Key) Algorithm() enum.AlgorithmEnum {
+	return k.algorithm
+}
+
+// Counter the initial HOTP counter
+func (k *Key) Counter() uint64 {
+	return k.counter
+}
+
+// Pattern the OTP generation pattern
+func (k *Key) Pattern() enum.PatternEnum {
+	return k.pattern
+}
+
+// Host the host of the key
+func (k *Key) Host() string {
+	return k.host
+}
+
+// String the key uri
+func (k *Key) String() string {
+	return k.uri
+}
+
+// CreateOtpCmd rebuild the OTP parameters described by the key
+func (k *Key) CreateOtpCmd() *CreateOtpCmd {
+	return &CreateOtpCmd{
+		Issuer:      k.issuer,
+		AccountName: k.accountName,
+		OtpType:     k.otpType,
+		Period:      k.period,
+		Counter:     k.counter,
+		EncSecret:   k.secret,
+		Digits:      int(k.digits),
+		Algorithm:   k.algorithm,
+		Pattern:     k.pattern,
+		Host:        k.host,
+	}
+}
+
+// QRCode encode the key uri as a QR code at o.Level
+func (k *Key) QRCode(o qrcode.Options) (*qrcode.Code, error) {
+	return qrcode.Encode(k.uri, o.Level)
+}
+
+// PNG render the key uri as a PNG QR code
+func (k *Key) PNG(o qrcode.Options) ([]byte, error) {
+	c, err := k.QRCode(o)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.PNG(o)
+}
+
+// SVG render the key uri as an SVG QR code
+func (k *Key) SVG(o qrcode.Options) (string, error) {
+	c, err := k.QRCode(o)
+	if err != nil {
+		return "", err
+	}
+
+	return c.SVG(o), nil
+}
+
+// Terminal render the key uri as a QR code of UTF-8 half blocks
+func (k *Key) Terminal(o qrcode.Options) (string, error) {
+	c, err := k.QRCode(o)
+	if err != nil {
+		return "", err
+	}
+
+	return c.Terminal(o), nil
+}
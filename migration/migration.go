@@ -0,0 +1,278 @@
+package migration
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/util"
+	"net/url"
+	"strings"
+)
+
+// Google Authenticator MigrationPayload field numbers and enum values
+const (
+	payloadOtpParameters = 1
+	payloadVersion       = 2
+	payloadBatchSize     = 3
+	payloadBatchIndex    = 4
+	payloadBatchID       = 5
+
+	paramSecret    = 1
+	paramName      = 2
+	paramIssuer    = 3
+	paramAlgorithm = 4
+	paramDigits    = 5
+	paramType      = 6
+	paramCounter   = 7
+
+	digitsSix   = 1
+	digitsEight = 2
+
+	typeHOTP = 1
+	typeTOTP = 2
+
+	version = 1
+)
+
+// DefaultBatchSize the accounts per payload used by Encode when batchSize is 0, small enough to fit a QR code
+const DefaultBatchSize = 10
+
+// Payload a decoded otpauth-migration://offline uri
+type Payload struct {
+	Keys       []*otp.Key // The accounts of this payload
+	Version    int        // The payload format version
+	BatchSize  int        // The number of payloads in the batch
+	BatchIndex int        // The index of this payload in the batch
+	BatchID    int32      // Identifies payloads of the same batch
+}
+
+// Decode parse an otpauth-migration://offline?data=... uri
+func Decode(uri string) (*Payload, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration uri: %w", err)
+	}
+	if u.Scheme != "otpauth-migration" || u.Host != "offline" {
+		return nil, errors.New("invalid migration uri: expected otpauth-migration://offline")
+	}
+	// unescaped '+' is read as a space by the query parser
+	data := strings.ReplaceAll(u.Query().Get("data"), " ", "+")
+	if data == "" {
+		return nil, errors.New("invalid migration uri: missing data")
+	}
+	raw, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		if raw, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); err != nil {
+			return nil, errors.New("invalid migration uri: data is not valid base64")
+		}
+	}
+
+	fields, err := decodeFields(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid migration payload: %w", err)
+	}
+	p := &Payload{}
+	for _, f := range fields {
+		switch f.num {
+		case payloadOtpParameters:
+			k, err := decodeParameters(f.bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid migration account %d: %w", len(p.Keys), err)
+			}
+			p.Keys = append(p.Keys, k)
+		case payloadVersion:
+			p.Version = int(f.varint)
+		case payloadBatchSize:
+			p.BatchSize = int(f.varint)
+		case payloadBatchIndex:
+			p.BatchIndex = int(f.varint)
+		case payloadBatchID:
+			p.BatchID = int32(f.varint)
+		}
+	}
+
+	return p, nil
+}
+
+// DecodeAll decode every payload of a batch and return the accounts in order,
+// checking that the batch is complete
+func DecodeAll(uris []string) ([]*otp.Key, error) {
+	payloads := make([]*Payload, len(uris))
+	for i, uri := range uris {
+		p, err := Decode(uri)
+		if err != nil {
+			return nil, err
+		}
+		payloads[i] = p
+	}
+	if len(payloads) == 0 {
+		return nil, nil
+	}
+
+	first := payloads[0]
+	ordered := make([]*Payload, max(first.BatchSize, 1))
+	if len(ordered) != len(payloads) {
+		return nil, fmt.Errorf("incomplete migration batch, got %d of %d payloads", len(payloads), len(ordered))
+	}
+	for _, p := range payloads {
+		if p.BatchID != first.BatchID || p.BatchSize != first.BatchSize {
+			return nil, errors.New("migration payloads belong to different batches")
+		}
+		if p.BatchIndex < 0 || p.BatchIndex >= len(ordered) || ordered[p.BatchIndex] != nil {
+			return nil, fmt.Errorf("invalid migration batch index %d", p.BatchIndex)
+		}
+		ordered[p.BatchIndex] = p
+	}
+
+	var keys []*otp.Key
+	for _, p := range ordered {
+		keys = append(keys, p.Keys...)
+	}
+	return keys, nil
+}
+
+func decodeParameters(b []byte) (*otp.Key, error) {
+	fields, err := decodeFields(b)
+	if err != nil {
+		return nil, err
+	}
+
+	cmd := &otp.CreateOtpCmd{OtpType: otp.TOTP, Digits: int(enum.DigitSix)}
+	var name string
+	var counter uint64
+	for _, f := range fields {
+		switch f.num {
+		case paramSecret:
+			cmd.Secret = string(f.bytes)
+		case paramName:
+			name = string(f.bytes)
+		case paramIssuer:
+			cmd.Issuer = string(f.bytes)
+		case paramAlgorithm:
+			switch f.varint {
+			case 0, 1:
+				cmd.Algorithm = enum.AlgorithmSHA1
+			case 2:
+				cmd.Algorithm = enum.AlgorithmSHA256
+			case 3:
+				cmd.Algorithm = enum.AlgorithmSHA512
+			case 4:
+				cmd.Algorithm = enum.AlgorithmMD5
+			default:
+				return nil, fmt.Errorf("unknown algorithm %d", f.varint)
+			}
+		case paramDigits:
+			switch f.varint {
+			case 0, digitsSix:
+				cmd.Digits = int(enum.DigitSix)
+			case digitsEight:
+				cmd.Digits = int(enum.DigitEight)
+			default:
+				return nil, fmt.Errorf("unknown digit count %d", f.varint)
+			}
+		case paramType:
+			switch f.varint {
+			case 0, typeTOTP:
+				cmd.OtpType = otp.TOTP
+			case typeHOTP:
+				cmd.OtpType = otp.HOTP
+			default:
+				return nil, fmt.Errorf("unknown otp type %d", f.varint)
+			}
+		case paramCounter:
+			counter = f.varint
+		}
+	}
+
+	// the name is usually "Issuer:account"
+	cmd.AccountName = name
+	if i := strings.Index(name, ":"); i >= 0 {
+		if cmd.Issuer == "" {
+			cmd.Issuer = strings.TrimSpace(name[:i])
+		}
+		cmd.AccountName = name[i+1:]
+	}
+
+	return otp.NewKey(cmd, counter)
+}
+
+// Encode split keys into otpauth-migration://offline uris of at most batchSize accounts each
+func Encode(keys []*otp.Key, batchSize int) ([]string, error) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	batchID := int32(binary.BigEndian.Uint32(id[:]) & 0x7fffffff)
+	batches := (len(keys) + batchSize - 1) / batchSize
+
+	uris := make([]string, 0, batches)
+	for i := 0; i < batches; i++ {
+		var b []byte
+		for _, k := range keys[i*batchSize : min((i+1)*batchSize, len(keys))] {
+			params, err := encodeParameters(k)
+			if err != nil {
+				return nil, fmt.Errorf("account %q: %w", k.AccountName(), err)
+			}
+			b = appendBytesField(b, payloadOtpParameters, params)
+		}
+		b = appendVarintField(b, payloadVersion, version)
+		b = appendVarintField(b, payloadBatchSize, uint64(batches))
+		b = appendVarintField(b, payloadBatchIndex, uint64(i))
+		b = appendVarintField(b, payloadBatchID, uint64(batchID))
+
+		val := url.Values{}
+		val.Set("data", base64.StdEncoding.EncodeToString(b))
+		uris = append(uris, "otpauth-migration://offline?"+val.Encode())
+	}
+
+	return uris, nil
+}
+
+func encodeParameters(k *otp.Key) ([]byte, error) {
+	if k.Pattern() != enum.Standard {
+		return nil, fmt.Errorf("pattern %q is not supported by Google Authenticator", k.Pattern())
+	}
+	if k.OtpType() == otp.TOTP && k.Period() != 30 {
+		return nil, fmt.Errorf("period %d is not supported by Google Authenticator", k.Period())
+	}
+	secret, err := util.DecodeBase32Secret(k.Secret())
+	if err != nil {
+		return nil, err
+	}
+
+	var digits uint64
+	switch k.Digits() {
+	case enum.DigitSix:
+		digits = digitsSix
+	case enum.DigitEight:
+		digits = digitsEight
+	default:
+		return nil, fmt.Errorf("%d digits are not supported by Google Authenticator", k.Digits())
+	}
+	typ := uint64(typeTOTP)
+	if k.OtpType() == otp.HOTP {
+		typ = typeHOTP
+	}
+	name := k.AccountName()
+	if k.Issuer() != "" {
+		name = k.Issuer() + ":" + name
+	}
+
+	var b []byte
+	b = appendBytesField(b, paramSecret, secret)
+	b = appendBytesField(b, paramName, []byte(name))
+	b = appendBytesField(b, paramIssuer, []byte(k.Issuer()))
+	b = appendVarintField(b, paramAlgorithm, uint64(k.Algorithm())+1)
+	b = appendVarintField(b, paramDigits, digits)
+	b = appendVarintField(b, paramType, typ)
+	b = appendVarintField(b, paramCounter, k.Counter())
+
+	return b, nil
+}
@@ -0,0 +1,71 @@
+package migration
+
+import (
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"testing"
+)
+
+func TestDecode(t *testing.T) {
+	p, err := Decode("otpauth-migration://offline?data=CjEKCkhlbGxvId6tvu8SGEV4YW1wbGU6YWxpY2VAZ29vZ2xlLmNvbRoHRXhhbXBsZTAC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Keys) != 1 {
+		t.Fatalf("got %d keys; want 1", len(p.Keys))
+	}
+	k := p.Keys[0]
+	if k.OtpType() != otp.TOTP || k.Issuer() != "Example" || k.AccountName() != "alice@google.com" ||
+		k.Secret() != "JBSWY3DPEHPK3PXP" || k.Digits() != enum.DigitSix || k.Algorithm() != enum.AlgorithmSHA1 {
+		t.Fatalf("unexpected key %s", k)
+	}
+}
+
+func TestEncodeDecodeBatches(t *testing.T) {
+	var keys []*otp.Key
+	for i, cmd := range []*otp.CreateOtpCmd{
+		{OtpType: otp.TOTP, Issuer: "ACME", AccountName: "alice", EncSecret: "JBSWY3DPEHPK3PXP"},
+		{OtpType: otp.HOTP, Issuer: "ACME", AccountName: "bob", EncSecret: "MRUGYYLOONUGC3Q", Digits: 8, Algorithm: enum.AlgorithmSHA256},
+		{OtpType: otp.TOTP, AccountName: "carol", Secret: "12345678901234567890", Algorithm: enum.AlgorithmSHA512},
+	} {
+		k, err := otp.NewKey(cmd, uint64(i*10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+
+	uris, err := Encode(keys, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(uris) != 2 {
+		t.Fatalf("got %d payloads; want 2", len(uris))
+	}
+	if _, err = DecodeAll(uris[:1]); err == nil {
+		t.Fatal("expected an incomplete batch to fail")
+	}
+
+	got, err := DecodeAll([]string{uris[1], uris[0]})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(keys) {
+		t.Fatalf("got %d keys; want %d", len(got), len(keys))
+	}
+	for i := range keys {
+		if got[i].String() != keys[i].String() {
+			t.Errorf("key %d = %s; want %s", i, got[i], keys[i])
+		}
+	}
+}
+
+func TestEncodeUnsupported(t *testing.T) {
+	k, err := otp.NewKey(&otp.CreateOtpCmd{OtpType: otp.TOTP, AccountName: "alice", EncSecret: "JBSWY3DPEHPK3PXP", Period: 60}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = Encode([]*otp.Key{k}, 0); err == nil {
+		t.Fatal("expected a 60 second period to be rejected")
+	}
+}
@@ -0,0 +1,89 @@
+package migration
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Minimal protobuf wire format support for MigrationPayload, avoiding a code generator dependency.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("truncated protobuf message")
+
+func appendTag(b []byte, field int, wire int) []byte {
+	return binary.AppendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = appendTag(b, field, wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// field a decoded protobuf field, bytes is set for length delimited fields
+type field struct {
+	num    int
+	varint uint64
+	bytes  []byte
+}
+
+// decodeFields split a message into its fields, skipping fixed width ones
+func decodeFields(b []byte) ([]field, error) {
+	var fields []field
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errTruncated
+		}
+		b = b[n:]
+		f := field{num: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			if f.varint, n = binary.Uvarint(b); n <= 0 {
+				return nil, errTruncated
+			}
+			b = b[n:]
+		case wireBytes:
+			l, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < l {
+				return nil, errTruncated
+			}
+			f.bytes = b[n : n+int(l)]
+			b = b[n+int(l):]
+		case wireFixed64:
+			if len(b) < 8 {
+				return nil, errTruncated
+			}
+			b = b[8:]
+			continue
+		case wireFixed32:
+			if len(b) < 4 {
+				return nil, errTruncated
+			}
+			b = b[4:]
+			continue
+		default:
+			return nil, errors.New("unsupported protobuf wire type")
+		}
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
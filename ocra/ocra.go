@@ -0,0 +1,161 @@
+package ocra
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/internal/util"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// questionSize the fixed size of the challenge in DataInput
+const questionSize = 128
+
+// Params the values of an OCRA computation, only those required by the suite are used
+type Params struct {
+	Counter   uint64    // The synchronized counter (C)
+	Question  string    // The challenge (Q), e.g. the transaction data to sign
+	Question2 string    // The second challenge of mutual challenge-response, appended to Question
+	PIN       string    // The PIN, hashed with the suite PIN algorithm (P)
+	PINHash   []byte    // A precomputed PIN hash, used when PIN is empty
+	Session   []byte    // The session information (S)
+	Time      time.Time // The timestamp (T)
+}
+
+// DataInput build the OCRA DataInput: suite | 0x00 | C | Q | P | S | T (RFC 6287 §5.1)
+func (s *Suite) DataInput(p Params) ([]byte, error) {
+	buf := make([]byte, 0, len(s.raw)+1+8+questionSize+64+s.SessionLength+8)
+	buf = append(buf, s.raw...)
+	buf = append(buf, 0)
+
+	if s.Counter {
+		buf = binary.BigEndian.AppendUint64(buf, p.Counter)
+	}
+
+	q, err := s.question(p.Question, p.Question2)
+	if err != nil {
+		return nil, err
+	}
+	buf = append(buf, q...)
+
+	if s.PIN {
+		h := p.PINHash
+		if p.PIN != "" {
+			hash := s.PINAlgorithm.Hash()
+			hash.Write([]byte(p.PIN))
+			h = hash.Sum(nil)
+		}
+		if len(h) != s.PINAlgorithm.Hash().Size() {
+			return nil, errors.New("missing or invalid pin hash")
+		}
+		buf = append(buf, h...)
+	}
+
+	if s.SessionLength > 0 {
+		if len(p.Session) > s.SessionLength {
+			return nil, fmt.Errorf("session information longer than %d bytes", s.SessionLength)
+		}
+		// left padded with zeros
+		buf = append(buf, make([]byte, s.SessionLength-len(p.Session))...)
+		buf = append(buf, p.Session...)
+	}
+
+	if s.TimeStep > 0 {
+		if p.Time.IsZero() {
+			return nil, errors.New("missing timestamp")
+		}
+		buf = binary.BigEndian.AppendUint64(buf, uint64(p.Time.Unix()/int64(s.TimeStep/time.Second)))
+	}
+
+	return buf, nil
+}
+
+// question encode the challenges into their 128 byte, right zero padded form
+func (s *Suite) question(qs ...string) ([]byte, error) {
+	var q string
+	for i, c := range qs {
+		if i > 0 && c == "" {
+			continue
+		}
+		if len(c) < 4 || len(c) > s.QuestionLength {
+			return nil, fmt.Errorf("challenge length must be between 4 and %d", s.QuestionLength)
+		}
+		q += c
+	}
+
+	var h string
+	switch s.QuestionFormat {
+	case 'N':
+		n, ok := new(big.Int).SetString(q, 10)
+		if !ok || n.Sign() < 0 {
+			return nil, errors.New("numeric challenge expected")
+		}
+		h = n.Text(16)
+	case 'H':
+		h = q
+	case 'A':
+		h = hex.EncodeToString([]byte(q))
+	}
+	if len(h) > 2*questionSize {
+		return nil, errors.New("challenge too long")
+	}
+	h += strings.Repeat("0", 2*questionSize-len(h))
+
+	b, err := hex.DecodeString(h)
+	if err != nil {
+		return nil, errors.New("hexadecimal challenge expected")
+	}
+	return b, nil
+}
+
+// Generate compute the OCRA response for key
+func (s *Suite) Generate(key []byte, p Params) (string, error) {
+	data, err := s.DataInput(p)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(s.Algorithm.Hash, key)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+	if s.Digits == 0 {
+		return hex.EncodeToString(sum), nil
+	}
+
+	return util.FormatDigits(util.DynamicTruncate(sum), s.Digits), nil
+}
+
+// Verify check response in constant time
+func (s *Suite) Verify(key []byte, response string, p Params) (bool, error) {
+	expected, err := s.Generate(key, p)
+	if err != nil {
+		return false, err
+	}
+
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(strings.TrimSpace(response))) == 1, nil
+}
+
+// Generate compute the OCRA response of suite for key
+func Generate(suite string, key []byte, p Params) (string, error) {
+	s, err := ParseSuite(suite)
+	if err != nil {
+		return "", err
+	}
+
+	return s.Generate(key, p)
+}
+
+// Verify check the OCRA response of suite for key
+func Verify(suite string, key []byte, response string, p Params) (bool, error) {
+	s, err := ParseSuite(suite)
+	if err != nil {
+		return false, err
+	}
+
+	return s.Verify(key, response, p)
+}
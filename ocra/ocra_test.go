@@ -0,0 +1,131 @@
+package ocra
+
+import (
+	"encoding/hex"
+	"testing"
+	"time"
+)
+
+// RFC 6287 Appendix C test vectors
+var (
+	key20, _ = hex.DecodeString("3132333435363738393031323334353637383930")
+	key32, _ = hex.DecodeString("3132333435363738393031323334353637383930313233343536373839303132")
+	key64, _ = hex.DecodeString("31323334353637383930313233343536373839303132333435363738393031323334353637383930313233343536373839303132333435363738393031323334")
+	// 0x132d0b6 minutes
+	timestamp = time.Unix(0x132d0b6*60, 0)
+)
+
+type vector struct {
+	suite    string
+	key      []byte
+	params   Params
+	response string
+}
+
+var vectors = []vector{
+	// One-way challenge response
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "00000000"}, "237653"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "11111111"}, "243178"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "22222222"}, "653583"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "33333333"}, "740991"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "44444444"}, "608993"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "55555555"}, "388898"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "66666666"}, "816933"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "77777777"}, "224598"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "88888888"}, "750600"},
+	{"OCRA-1:HOTP-SHA1-6:QN08", key20, Params{Question: "99999999"}, "294470"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 0, Question: "12345678", PIN: "1234"}, "65347737"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 1, Question: "12345678", PIN: "1234"}, "86775851"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 2, Question: "12345678", PIN: "1234"}, "78192410"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 3, Question: "12345678", PIN: "1234"}, "71565254"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 4, Question: "12345678", PIN: "1234"}, "10104329"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 5, Question: "12345678", PIN: "1234"}, "65983500"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 6, Question: "12345678", PIN: "1234"}, "70069104"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 7, Question: "12345678", PIN: "1234"}, "91771096"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 8, Question: "12345678", PIN: "1234"}, "75011558"},
+	{"OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1", key32, Params{Counter: 9, Question: "12345678", PIN: "1234"}, "08522129"},
+	{"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", key32, Params{Question: "00000000", PIN: "1234"}, "83238735"},
+	{"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", key32, Params{Question: "11111111", PIN: "1234"}, "01501458"},
+	{"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", key32, Params{Question: "22222222", PIN: "1234"}, "17957585"},
+	{"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", key32, Params{Question: "33333333", PIN: "1234"}, "86776967"},
+	{"OCRA-1:HOTP-SHA256-8:QN08-PSHA1", key32, Params{Question: "44444444", PIN: "1234"}, "86807031"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 0, Question: "00000000"}, "07016083"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 1, Question: "11111111"}, "63947962"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 2, Question: "22222222"}, "70123924"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 3, Question: "33333333"}, "25341727"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 4, Question: "44444444"}, "33203315"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 5, Question: "55555555"}, "34205738"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 6, Question: "66666666"}, "44343969"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 7, Question: "77777777"}, "51946085"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 8, Question: "88888888"}, "20403879"},
+	{"OCRA-1:HOTP-SHA512-8:C-QN08", key64, Params{Counter: 9, Question: "99999999"}, "31409299"},
+	{"OCRA-1:HOTP-SHA512-8:QN08-T1M", key64, Params{Question: "00000000", Time: timestamp}, "95209754"},
+	{"OCRA-1:HOTP-SHA512-8:QN08-T1M", key64, Params{Question: "11111111", Time: timestamp}, "55907591"},
+	{"OCRA-1:HOTP-SHA512-8:QN08-T1M", key64, Params{Question: "22222222", Time: timestamp}, "22048402"},
+	{"OCRA-1:HOTP-SHA512-8:QN08-T1M", key64, Params{Question: "33333333", Time: timestamp}, "24218844"},
+	{"OCRA-1:HOTP-SHA512-8:QN08-T1M", key64, Params{Question: "44444444", Time: timestamp}, "36209546"},
+	// Mutual challenge response, server computation
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "CLI22220", Question2: "SRV11110"}, "28247970"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "CLI22221", Question2: "SRV11111"}, "01984843"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "CLI22222", Question2: "SRV11112"}, "65387857"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "CLI22223", Question2: "SRV11113"}, "03351211"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "CLI22224", Question2: "SRV11114"}, "83412541"},
+	// Mutual challenge response, client computation
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SRV11110", Question2: "CLI22220"}, "15510767"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SRV11111", Question2: "CLI22221"}, "90175646"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SRV11112", Question2: "CLI22222"}, "33777207"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SRV11113", Question2: "CLI22223"}, "95285278"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SRV11114", Question2: "CLI22224"}, "28934924"},
+	// Plain signature
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SIG10000"}, "53095496"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SIG11000"}, "04110475"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SIG12000"}, "31331128"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SIG13000"}, "76028668"},
+	{"OCRA-1:HOTP-SHA256-8:QA08", key32, Params{Question: "SIG14000"}, "46554205"},
+	{"OCRA-1:HOTP-SHA512-8:QA10-T1M", key64, Params{Question: "SIG1000000", Time: timestamp}, "77537423"},
+	{"OCRA-1:HOTP-SHA512-8:QA10-T1M", key64, Params{Question: "SIG1100000", Time: timestamp}, "31970405"},
+	{"OCRA-1:HOTP-SHA512-8:QA10-T1M", key64, Params{Question: "SIG1200000", Time: timestamp}, "10235557"},
+	{"OCRA-1:HOTP-SHA512-8:QA10-T1M", key64, Params{Question: "SIG1300000", Time: timestamp}, "95213541"},
+	{"OCRA-1:HOTP-SHA512-8:QA10-T1M", key64, Params{Question: "SIG1400000", Time: timestamp}, "65360607"},
+}
+
+func TestRFC6287Vectors(t *testing.T) {
+	for _, v := range vectors {
+		got, err := Generate(v.suite, v.key, v.params)
+		if err != nil {
+			t.Errorf("%s %+v: %v", v.suite, v.params, err)
+			continue
+		}
+		if got != v.response {
+			t.Errorf("%s %+v = %s; want %s", v.suite, v.params, got, v.response)
+		}
+		if ok, err := Verify(v.suite, v.key, v.response, v.params); !ok || err != nil {
+			t.Errorf("%s %+v: Verify = %v, %v", v.suite, v.params, ok, err)
+		}
+	}
+}
+
+func TestParseSuite(t *testing.T) {
+	s, err := ParseSuite("OCRA-1:HOTP-SHA256-8:C-QN08-PSHA1-S064-T1M")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Counter || s.QuestionFormat != 'N' || s.QuestionLength != 8 || !s.PIN || s.SessionLength != 64 || s.TimeStep != time.Minute || s.Digits != 8 {
+		t.Fatalf("unexpected suite %+v", s)
+	}
+
+	for _, suite := range []string{
+		"OCRA-2:HOTP-SHA1-6:QN08",
+		"OCRA-1:HOTP-MD5-6:QN08",
+		"OCRA-1:HOTP-SHA1-3:QN08",
+		"OCRA-1:HOTP-SHA1-6:C",
+		"OCRA-1:HOTP-SHA1-6:QX08",
+		"OCRA-1:HOTP-SHA1-6:QN65",
+		"OCRA-1:HOTP-SHA1-6:QN08-T1M-PSHA1",
+		"OCRA-1:HOTP-SHA1-6:QN08-T60M",
+	} {
+		if _, err := ParseSuite(suite); err == nil {
+			t.Errorf("ParseSuite(%q) expected error", suite)
+		}
+	}
+}
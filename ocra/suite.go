@@ -0,0 +1,166 @@
+package ocra
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Suite a parsed OCRA suite, e.g. OCRA-1:HOTP-SHA256-8:QN08-PSHA1-T1M (RFC 6287 §6)
+type Suite struct {
+	raw            string
+	Algorithm      enum.AlgorithmEnum // The HMAC algorithm of the crypto function
+	Digits         int                // The response length, 0 means no truncation
+	Counter        bool               // Whether the DataInput contains a counter (C)
+	QuestionFormat byte               // The challenge format, 'A' alphanumeric, 'N' numeric or 'H' hexadecimal
+	QuestionLength int                // The maximum challenge length, 4 to 64
+	PIN            bool               // Whether the DataInput contains a PIN hash (P)
+	PINAlgorithm   enum.AlgorithmEnum // The PIN hash algorithm
+	SessionLength  int                // The session information length in bytes (S), 0 when absent
+	TimeStep       time.Duration      // The timestamp step (T), 0 when absent
+}
+
+// ParseSuite parse an OCRA suite string
+func ParseSuite(s string) (*Suite, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid ocra suite %q", s)
+	}
+	if parts[0] != "OCRA-1" {
+		return nil, fmt.Errorf("unsupported ocra version %q", parts[0])
+	}
+
+	suite := &Suite{raw: s}
+	if err := suite.parseCryptoFunction(parts[1]); err != nil {
+		return nil, err
+	}
+	if err := suite.parseDataInput(parts[2]); err != nil {
+		return nil, err
+	}
+
+	return suite, nil
+}
+
+// parseCryptoFunction parse HOTP-SHAx-t
+func (s *Suite) parseCryptoFunction(cf string) error {
+	fields := strings.Split(cf, "-")
+	if len(fields) != 3 || fields[0] != "HOTP" {
+		return fmt.Errorf("invalid ocra crypto function %q", cf)
+	}
+	alg, err := parseHash(fields[1])
+	if err != nil {
+		return err
+	}
+	digits, err := strconv.Atoi(fields[2])
+	if err != nil || (digits != 0 && (digits < 4 || digits > 10)) {
+		return fmt.Errorf("invalid ocra truncation length %q", fields[2])
+	}
+	s.Algorithm, s.Digits = alg, digits
+
+	return nil
+}
+
+// parseDataInput parse [C][-QFxx][-PH][-Snnn][-TG]
+func (s *Suite) parseDataInput(di string) error {
+	fields := strings.Split(di, "-")
+	if len(fields) > 0 && fields[0] == "C" {
+		s.Counter = true
+		fields = fields[1:]
+	}
+	if len(fields) == 0 || len(fields[0]) != 4 || fields[0][0] != 'Q' {
+		return fmt.Errorf("invalid ocra data input %q, missing challenge", di)
+	}
+
+	q := fields[0]
+	s.QuestionFormat = q[1]
+	if s.QuestionFormat != 'A' && s.QuestionFormat != 'N' && s.QuestionFormat != 'H' {
+		return fmt.Errorf("invalid ocra challenge format %q", q)
+	}
+	n, err := strconv.Atoi(q[2:])
+	if err != nil || n < 4 || n > 64 {
+		return fmt.Errorf("invalid ocra challenge length %q", q)
+	}
+	s.QuestionLength = n
+
+	for _, f := range fields[1:] {
+		if f == "" {
+			return fmt.Errorf("invalid ocra data input %q", di)
+		}
+		switch f[0] {
+		case 'P':
+			if s.PIN || s.SessionLength > 0 || s.TimeStep > 0 {
+				return fmt.Errorf("invalid ocra data input %q, misplaced %q", di, f)
+			}
+			if s.PINAlgorithm, err = parseHash(f[1:]); err != nil {
+				return err
+			}
+			s.PIN = true
+		case 'S':
+			if s.SessionLength > 0 || s.TimeStep > 0 {
+				return fmt.Errorf("invalid ocra data input %q, misplaced %q", di, f)
+			}
+			n, err := strconv.Atoi(f[1:])
+			if err != nil || len(f) != 4 || n <= 0 || n > 512 {
+				return fmt.Errorf("invalid ocra session length %q", f)
+			}
+			s.SessionLength = n
+		case 'T':
+			if s.TimeStep > 0 {
+				return fmt.Errorf("invalid ocra data input %q, misplaced %q", di, f)
+			}
+			if s.TimeStep, err = parseTimeStep(f[1:]); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("invalid ocra data input %q, unknown %q", di, f)
+		}
+	}
+
+	return nil
+}
+
+func parseHash(name string) (enum.AlgorithmEnum, error) {
+	switch name {
+	case "SHA1":
+		return enum.AlgorithmSHA1, nil
+	case "SHA256":
+		return enum.AlgorithmSHA256, nil
+	case "SHA512":
+		return enum.AlgorithmSHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported ocra hash %q", name)
+}
+
+// parseTimeStep parse the G of TG: [1-59]S, [1-59]M or [0-48]H
+func parseTimeStep(g string) (time.Duration, error) {
+	if len(g) < 2 {
+		return 0, fmt.Errorf("invalid ocra time step %q", g)
+	}
+	n, err := strconv.Atoi(g[:len(g)-1])
+	if err != nil {
+		return 0, fmt.Errorf("invalid ocra time step %q", g)
+	}
+	switch g[len(g)-1] {
+	case 'S':
+		if n >= 1 && n <= 59 {
+			return time.Duration(n) * time.Second, nil
+		}
+	case 'M':
+		if n >= 1 && n <= 59 {
+			return time.Duration(n) * time.Minute, nil
+		}
+	case 'H':
+		if n >= 1 && n <= 48 {
+			return time.Duration(n) * time.Hour, nil
+		}
+	}
+	return 0, errors.New("invalid ocra time step " + strconv.Quote(g))
+}
+
+// String the suite string
+func (s *Suite) String() string {
+	return s.raw
+}
@@ -1,26 +1,36 @@
 package otp
 
 import (
-	"encoding/json"
-	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
 	"github.com/dhlanshan/otp/hotp"
 	"github.com/dhlanshan/otp/internal/abstract"
-	"github.com/dhlanshan/otp/internal/command"
 	"github.com/dhlanshan/otp/totp"
 	"strings"
+	"time"
 )
 
+// VerifyResult the outcome of verifying a dynamic code
+type VerifyResult = abstract.VerifyResult
+
+// Request the typed parameters of generating or verifying a dynamic code
+type Request = abstract.Request
+
+// timeBased is implemented by OTP types whose codes depend on time
+type timeBased interface {
+	GenerateCodeAt(tm time.Time, counters ...any) ([]string, error)
+	ValidateAt(passCode string, tm time.Time, counters ...any) (bool, error)
+}
+
+// NewOtpInstance build the HOTP or TOTP instance described by cmd
 func NewOtpInstance(cmd *CreateOtpCmd) (abstract.Otp, error) {
-	var newCmd *command.CreateOtpCmd
-	n, _ := json.Marshal(cmd)
-	_ = json.Unmarshal(n, &newCmd)
 	switch cmd.OtpType {
 	case HOTP:
-		return hotp.NewHOtp(newCmd)
+		return hotp.New(cmd.hotpOptions()...)
 	case TOTP:
-		return totp.NewTOtp(newCmd)
+		return totp.New(cmd.totpOptions()...)
 	default:
-		return nil, errors.New("unsupported OTP type")
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedOtpType, cmd.OtpType)
 	}
 }
 
@@ -36,6 +46,16 @@ func GenerateKey(cmd *CreateOtpCmd) (string, error) {
 	return k, err
 }
 
+// GenerateKeyObject generate token KEY, returning the parsed key so it can be rendered as a QR code
+func GenerateKeyObject(cmd *CreateOtpCmd) (*Key, error) {
+	k, err := GenerateKey(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseKey(k)
+}
+
 // GenerateCode generate dynamic password
 func GenerateCode(cmd *CreateOtpCmd, counters ...any) (string, error) {
 	obj, err := NewOtpInstance(cmd)
@@ -58,3 +78,123 @@ func Validate(cmd *CreateOtpCmd, passCode string, counters ...any) bool {
 
 	return res
 }
+
+// GenerateCodeAt generate the dynamic password at the specified time. HOTP ignores tm
+func GenerateCodeAt(cmd *CreateOtpCmd, tm time.Time, counters ...any) (string, error) {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	var code []string
+	if tb, ok := obj.(timeBased); ok {
+		code, err = tb.GenerateCodeAt(tm, counters...)
+	} else {
+		code, err = obj.GenerateCode(counters...)
+	}
+
+	return strings.Join(code, ""), err
+}
+
+// GenerateCodeInfo generate the TOTP dynamic password with its time step and remaining validity
+func GenerateCodeInfo(cmd *CreateOtpCmd, counters ...any) (totp.CodeInfo, error) {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return totp.CodeInfo{}, err
+	}
+	tObj, ok := obj.(*totp.TOtp)
+	if !ok {
+		return totp.CodeInfo{}, fmt.Errorf("%w: code expiry is only supported by TOTP", ErrUnsupportedOtpType)
+	}
+
+	return tObj.GenerateCodeInfo(counters...)
+}
+
+// ValidateAt verify dynamic code at the specified time. HOTP ignores tm
+func ValidateAt(cmd *CreateOtpCmd, passCode string, tm time.Time, counters ...any) bool {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return false
+	}
+
+	var res bool
+	if tb, ok := obj.(timeBased); ok {
+		res, _ = tb.ValidateAt(passCode, tm, counters...)
+	} else {
+		res, _ = obj.Validate(passCode, counters...)
+	}
+
+	return res
+}
+
+// Resync recover a desynchronized HOTP token from two consecutive codes, returning the counter
+// matched by code2. The caller should persist the returned counter+1
+func Resync(cmd *CreateOtpCmd, code1, code2 string, counter uint64, pins ...string) (uint64, error) {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return 0, err
+	}
+	hObj, ok := obj.(*hotp.HOtp)
+	if !ok {
+		return 0, fmt.Errorf("%w: resync is only supported by HOTP", ErrUnsupportedOtpType)
+	}
+	var pin string
+	if len(pins) > 0 {
+		pin = pins[0]
+	}
+
+	return hObj.Resync(code1, code2, counter, pin)
+}
+
+// Verify verify dynamic code and report the matched step, drift and failure reason
+func Verify(cmd *CreateOtpCmd, passCode string, counters ...any) VerifyResult {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return VerifyResult{Reason: enum.ReasonError, Err: err}
+	}
+
+	return obj.Verify(passCode, counters...)
+}
+
+// GenerateCodeWith generate dynamic password from typed parameters
+func GenerateCodeWith(cmd *CreateOtpCmd, req Request) (string, error) {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := obj.GenerateCodeWith(req)
+
+	return strings.Join(code, ""), err
+}
+
+// ValidateWith verify dynamic code with typed parameters
+func ValidateWith(cmd *CreateOtpCmd, passCode string, req Request) bool {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return false
+	}
+	res, _ := obj.ValidateWith(passCode, req)
+
+	return res
+}
+
+// ValidateErr verify dynamic code, returning nil when it is accepted and the classified error otherwise
+func ValidateErr(cmd *CreateOtpCmd, passCode string, counters ...any) error {
+	return Verify(cmd, passCode, counters...).AsError()
+}
+
+// ValidateWithErr verify dynamic code with typed parameters, returning nil when it is accepted and the classified error otherwise
+func ValidateWithErr(cmd *CreateOtpCmd, passCode string, req Request) error {
+	return VerifyWith(cmd, passCode, req).AsError()
+}
+
+// VerifyWith verify dynamic code with typed parameters and report the matched step, drift and failure reason
+func VerifyWith(cmd *CreateOtpCmd, passCode string, req Request) VerifyResult {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return VerifyResult{Reason: enum.ReasonError, Err: err}
+	}
+
+	return obj.VerifyWith(passCode, req)
+}
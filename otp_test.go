@@ -1,73 +1,109 @@
 package otp
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/hotp"
+	"github.com/dhlanshan/otp/internal/command"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/qrcode"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/sealed"
+	"github.com/dhlanshan/otp/totp"
+	"strings"
 	"testing"
+	"time"
 )
 
+// at a fixed time for the tests of the "dhlanshan" secret, MRUGYYLOONUGC3Q in base32
+var at = time.Unix(1700000000, 0)
+
 func TestGenerateKeyByHOtp(t *testing.T) {
 	cmd := &CreateOtpCmd{OtpType: HOTP, Secret: "dhlanshan"}
 	key, err := GenerateKey(cmd)
-	fmt.Println(key, err)
+	if err != nil || !strings.HasPrefix(key, "otpauth://hotp/") || !strings.Contains(key, "secret=MRUGYYLOONUGC3Q") {
+		t.Fatalf("GenerateKey = %q, %v", key, err)
+	}
 }
 
 func TestGenerateCodeByHOtp(t *testing.T) {
 	cmd := &CreateOtpCmd{OtpType: HOTP, Secret: "dhlanshan"}
-	code, err := GenerateCode(cmd, uint64(2))
-	fmt.Println(code, err)
+	code, err := GenerateCode(cmd, uint64(3))
+	if err != nil || code != "358324" {
+		t.Fatalf("GenerateCode = %q, %v; want 358324", code, err)
+	}
 }
 
 func TestValidateByHOtp(t *testing.T) {
 	passCode := "358324"
 	cmd := &CreateOtpCmd{OtpType: HOTP, EncSecret: "MRUGYYLOONUGC3Q"}
-	res := Validate(cmd, passCode, uint64(3))
-	fmt.Println(res)
+	if !Validate(cmd, passCode, uint64(3)) {
+		t.Fatal("valid code rejected")
+	}
+	if Validate(cmd, passCode, uint64(4)) {
+		t.Fatal("code of another counter accepted")
+	}
 }
 
 func TestGenerateKeyByTOtp(t *testing.T) {
 	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "dhlanshan"}
 	key, err := GenerateKey(cmd)
-	fmt.Println(key, err)
+	if err != nil || !strings.HasPrefix(key, "otpauth://totp/") || !strings.Contains(key, "period=30") {
+		t.Fatalf("GenerateKey = %q, %v", key, err)
+	}
 }
 
 func TestGenerateCodeByTOtp(t *testing.T) {
-	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "dhlanshan"}
+	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "dhlanshan", Clock: totp.NewFakeClock(at)}
 	code, err := GenerateCode(cmd)
-	fmt.Println(code, err)
+	if err != nil || code != "710248" {
+		t.Fatalf("GenerateCode = %q, %v; want 710248", code, err)
+	}
 }
 
 func TestValidateByTOtp(t *testing.T) {
-	passCode := "585641"
+	passCode := "710248"
 	cmd := &CreateOtpCmd{OtpType: TOTP, EncSecret: "MRUGYYLOONUGC3Q", Skew: 1}
-	res := Validate(cmd, passCode)
-	fmt.Println(res)
+	if !ValidateAt(cmd, passCode, at) || !ValidateAt(cmd, passCode, at.Add(30*time.Second)) {
+		t.Fatal("valid code rejected")
+	}
+	if ValidateAt(cmd, passCode, at.Add(90*time.Second)) {
+		t.Fatal("code outside the skew accepted")
+	}
 }
 
 func TestGenerateKeyBySteam(t *testing.T) {
 	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "dhlanshan", Pattern: enum.Steam}
 	key, err := GenerateKey(cmd)
-	fmt.Println(key, err)
+	if err != nil || !strings.HasPrefix(key, "otpauth://steam/") || !strings.Contains(key, "digits=5") {
+		t.Fatalf("GenerateKey = %q, %v", key, err)
+	}
 }
 
 func TestGenerateCodeBySteam(t *testing.T) {
 	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "dhlanshan", Pattern: enum.Steam}
-	code, err := GenerateCode(cmd)
-	fmt.Println(code, err)
+	code, err := GenerateCodeAt(cmd, at)
+	if err != nil || code != "2R4W8" {
+		t.Fatalf("GenerateCodeAt = %q, %v; want 2R4W8", code, err)
+	}
 }
 
 func TestValidateBySteam(t *testing.T) {
-	passCode := "47M6M"
+	passCode := "2R4W8"
 	cmd := &CreateOtpCmd{OtpType: TOTP, EncSecret: "MRUGYYLOONUGC3Q", Pattern: enum.Steam, Skew: 1}
-	res := Validate(cmd, passCode)
-	fmt.Println(res)
+	if !ValidateAt(cmd, passCode, at) {
+		t.Fatal("valid code rejected")
+	}
 }
 
 func TestGenerateKeyByMobile(t *testing.T) {
 	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "dhlanshan", Pattern: enum.Mobile}
 	key, err := GenerateKey(cmd)
-	fmt.Println(key, err)
+	if err != nil || !strings.HasPrefix(key, "otpauth://mobile/") {
+		t.Fatalf("GenerateKey = %q, %v", key, err)
+	}
 }
 
 // MobilePattern Mobile模式
@@ -96,15 +132,686 @@ func (mp *MobilePattern) CalculationFun(value int64, dl int, digits enum.DigitEn
 
 func TestGenerateCodeByMobile(t *testing.T) {
 	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "dhlanshan", Pattern: enum.Mobile, Period: 30}
-	AddOtpPattern([]Aop{{PatternName: "mobile", Pattern: &MobilePattern{}}})
-	code, err := GenerateCode(cmd, "6688")
-	fmt.Println(code, err)
+	code, err := GenerateCodeAt(cmd, at, "6688")
+	if err != nil || code != "chDRev" {
+		t.Fatalf("GenerateCodeAt = %q, %v; want chDRev", code, err)
+	}
 }
 
 func TestValidateByMobile(t *testing.T) {
-	passCode := "aIkg5C"
-	AddOtpPattern([]Aop{{PatternName: "mobile", Pattern: &MobilePattern{}}})
-	cmd := &CreateOtpCmd{OtpType: TOTP, EncSecret: "MRUGYYLOONUGC3Q", Pattern: enum.Mobile, Period: 30}
-	res := Validate(cmd, passCode, "6688")
-	fmt.Println(res)
+	passCode := "chDRev"
+	// The test pattern matches the built-in one
+	reg := pattern.NewRegistry()
+	if err := reg.Register("mobile2", &MobilePattern{}); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []enum.PatternEnum{enum.Mobile, "mobile2"} {
+		cmd := &CreateOtpCmd{OtpType: TOTP, EncSecret: "MRUGYYLOONUGC3Q", Pattern: p, Period: 30, Registry: reg}
+		if !ValidateAt(cmd, passCode, at, "6688") {
+			t.Fatalf("%s: valid code rejected", p)
+		}
+		if ValidateAt(cmd, passCode, at, "6689") {
+			t.Fatalf("%s: code accepted with the wrong pin", p)
+		}
+	}
+}
+
+func TestGenerateCodeAtByTOtp(t *testing.T) {
+	// RFC 6238 Appendix B, SHA1
+	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8}
+	code, err := GenerateCodeAt(cmd, time.Unix(59, 0))
+	if err != nil || code != "94287082" {
+		t.Fatalf("GenerateCodeAt = %q, %v; want 94287082", code, err)
+	}
+}
+
+func TestValidateWithFakeClock(t *testing.T) {
+	clock := totp.NewFakeClock(time.Unix(1111111109, 0))
+	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8, Clock: clock}
+	if !Validate(cmd, "07081804") {
+		t.Fatal("expected code to be valid at the fake time")
+	}
+	clock.Advance(30 * time.Second)
+	if Validate(cmd, "07081804") {
+		t.Fatal("expected code to be rejected after the step passed")
+	}
+	if !ValidateAt(cmd, "07081804", time.Unix(1111111109, 0)) {
+		t.Fatal("expected ValidateAt to use the given time")
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	cmd := &CreateOtpCmd{Issuer: "上天揽月", AccountName: "bee", OtpType: TOTP, EncSecret: "E6GI4IVJTVFFIDA67SDJ5KC647AZHQTM", Period: 60, Algorithm: enum.AlgorithmSHA256}
+	uri, err := GenerateKey(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.OtpType() != TOTP || key.Issuer() != "上天揽月" || key.AccountName() != "bee" || key.Period() != 60 ||
+		key.Algorithm() != enum.AlgorithmSHA256 || key.Digits() != enum.DigitSix || key.Secret() != cmd.EncSecret {
+		t.Fatalf("unexpected key %+v", key)
+	}
+
+	key, err = ParseKey("otpauth://steam/Steam:bee?secret=MRUGYYLOONUGC3Q&issuer=Steam")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.OtpType() != TOTP || key.Pattern() != enum.Steam || key.Digits() != 5 {
+		t.Fatalf("unexpected steam key %+v", key)
+	}
+
+	key, err = ParseKey("otpauth://hotp/ACME:alice?secret=MRUGYYLOONUGC3Q&counter=7&digits=8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.OtpType() != HOTP || key.Counter() != 7 || key.CreateOtpCmd().Digits != 8 {
+		t.Fatalf("unexpected hotp key %+v", key)
+	}
+}
+
+func TestKeyLabelRoundTrip(t *testing.T) {
+	want := "otpauth://totp/%E4%B8%8A%E5%A4%A9%E6%8F%BD%E6%9C%88:bee?"
+	uri, err := GenerateKey(&CreateOtpCmd{Issuer: "上天揽月", AccountName: "bee", OtpType: TOTP, EncSecret: "E6GI4IVJTVFFIDA67SDJ5KC647AZHQTM"})
+	if err != nil || !strings.HasPrefix(uri, want) {
+		t.Fatalf("GenerateKey = %q, %v; want the label escaped once", uri, err)
+	}
+
+	for _, otpType := range []TypeEnum{HOTP, TOTP} {
+		for _, account := range []string{"50%off", "50%25off", "a b/c?d#e"} {
+			cmd := &CreateOtpCmd{Issuer: "ACME %", AccountName: account, OtpType: otpType, Secret: "12345678901234567890"}
+			uri, err := GenerateKey(cmd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			key, err := ParseKey(uri)
+			if err != nil {
+				t.Fatalf("%s %q: %v", otpType, uri, err)
+			}
+			if key.AccountName() != account || key.Issuer() != cmd.Issuer {
+				t.Errorf("%s %q = %q, %q; want %q, %q", otpType, uri, key.Issuer(), key.AccountName(), cmd.Issuer, account)
+			}
+		}
+	}
+}
+
+func TestParseKeyHOTP(t *testing.T) {
+	uri, err := GenerateKey(&CreateOtpCmd{OtpType: HOTP, Issuer: "ACME", AccountName: "alice", Secret: "12345678901234567890", Counter: 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(uri, "otpauth://hotp/") || !strings.Contains(uri, "counter=7") || strings.Contains(uri, "type=") {
+		t.Fatalf("hotp uri = %s", uri)
+	}
+	if key, err := ParseKey(uri); err != nil || key.OtpType() != HOTP || key.Counter() != 7 {
+		t.Fatalf("hotp key = %+v, %v", key, err)
+	}
+
+	// keys of other patterns carry the type explicitly and round trip as HOTP
+	cmd := &CreateOtpCmd{OtpType: HOTP, Issuer: "ACME", AccountName: "alice", Secret: "12345678901234567890", Pattern: enum.Steam}
+	uri, err = GenerateKey(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(uri, "counter=0") || !strings.Contains(uri, "type=hotp") {
+		t.Fatalf("steam hotp uri = %s", uri)
+	}
+	key, err := ParseKey(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.OtpType() != HOTP || key.Pattern() != enum.Steam || key.Period() != 0 {
+		t.Fatalf("steam hotp key = %+v", key)
+	}
+	want, _ := GenerateCode(cmd, uint64(3))
+	if code, err := GenerateCode(key.CreateOtpCmd(), uint64(3)); err != nil || code != want {
+		t.Fatalf("parsed key code = %q, %v; want %s", code, err, want)
+	}
+
+	nk, err := NewKey(cmd, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key, err = ParseKey(nk.String()); err != nil || key.OtpType() != HOTP || key.Counter() != 4 {
+		t.Fatalf("NewKey round trip = %+v, %v", key, err)
+	}
+
+	// a custom host without a type is time based
+	if key, err = ParseKey("otpauth://mobile/ACME:alice?secret=MRUGYYLOONUGC3Q&counter=1"); err != nil || key.OtpType() != TOTP {
+		t.Fatalf("untyped custom key = %+v, %v", key, err)
+	}
+}
+
+func TestNewKeyErrors(t *testing.T) {
+	if _, err := NewKey(&CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890"}, 0); !errors.Is(err, ErrMissingAccount) {
+		t.Fatalf("missing account = %v", err)
+	}
+	for _, alg := range []enum.AlgorithmEnum{9, -1} {
+		if _, err := NewKey(&CreateOtpCmd{OtpType: TOTP, AccountName: "alice", Secret: "12345678901234567890", Algorithm: alg}, 0); !errors.Is(err, ErrInvalidAlgorithm) {
+			t.Fatalf("unknown algorithm %d = %v", alg, err)
+		}
+	}
+	if _, err := NewKey(&CreateOtpCmd{OtpType: TOTP, AccountName: "alice"}, 0); !errors.Is(err, ErrMissingSecret) {
+		t.Fatalf("missing secret = %v", err)
+	}
+}
+
+func TestParseKeyInvalid(t *testing.T) {
+	for _, uri := range []string{
+		"https://totp/ACME:alice?secret=MRUGYYLOONUGC3Q",
+		"otpauth://totp/ACME:alice",
+		"otpauth://totp/ACME:alice?secret=!!!",
+		"otpauth://totp/ACME:?secret=MRUGYYLOONUGC3Q",
+		"otpauth://totp/ACME:alice?secret=MRUGYYLOONUGC3Q&issuer=Other",
+		"otpauth://totp/ACME:alice?secret=MRUGYYLOONUGC3Q&algorithm=SHA3",
+		"otpauth://totp/ACME:alice?secret=MRUGYYLOONUGC3Q&digits=11",
+		"otpauth://totp/ACME:alice?secret=MRUGYYLOONUGC3Q&period=0",
+		"otpauth://hotp/ACME:alice?secret=MRUGYYLOONUGC3Q&counter=-1",
+		"otpauth://mobile/ACME:alice?secret=MRUGYYLOONUGC3Q&type=motp",
+	} {
+		if _, err := ParseKey(uri); err == nil {
+			t.Errorf("ParseKey(%q) expected error", uri)
+		}
+	}
+}
+
+func TestValidateLookAheadByHOtp(t *testing.T) {
+	// RFC 4226 Appendix D: counter 5 => 254676
+	cmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890"}
+	if Validate(cmd, "254676", uint64(3)) {
+		t.Fatal("expected code outside the window to be rejected")
+	}
+	cmd.LookAhead = 2
+	if !Validate(cmd, "254676", uint64(3)) {
+		t.Fatal("expected code inside the look-ahead window to be accepted")
+	}
+
+	obj, _ := NewOtpInstance(cmd)
+	matched, ok, err := obj.(*hotp.HOtp).ValidateLookAhead("254676", 3, "")
+	if err != nil || !ok || matched != 5 {
+		t.Fatalf("ValidateLookAhead = %d, %v, %v; want 5", matched, ok, err)
+	}
+}
+
+func TestResyncByHOtp(t *testing.T) {
+	cmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890"}
+	// counters 7 and 8 => 162583, 399871
+	next, err := Resync(cmd, "162583", "399871", 1)
+	if err != nil || next != 8 {
+		t.Fatalf("Resync = %d, %v; want 8", next, err)
+	}
+	if _, err = Resync(cmd, "162583", "520489", 1); err == nil {
+		t.Fatal("expected non-consecutive codes to fail")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	clock := totp.NewFakeClock(time.Unix(1111111109+30, 0))
+	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8, Skew: 1, Clock: clock, ReplayStore: replay.NewMemoryStore()}
+	res := Verify(cmd, "07081804")
+	if !res.Valid || res.Counter != 37037036 || res.Drift != -1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if res = Verify(cmd, "07081804"); res.Valid || res.Reason != enum.ReasonReplay {
+		t.Fatalf("expected replay, got %+v", res)
+	}
+	if res = Verify(cmd, "0708180"); res.Reason != enum.ReasonWrongLength {
+		t.Fatalf("expected wrong length, got %+v", res)
+	}
+	if res = Verify(cmd, "12345678"); res.Reason != enum.ReasonMismatch {
+		t.Fatalf("expected mismatch, got %+v", res)
+	}
+
+	hCmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890", LookAhead: 3}
+	if res = Verify(hCmd, "254676", uint64(3)); !res.Valid || res.Counter != 5 || res.Drift != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if res = Verify(hCmd, "254676"); res.Reason != enum.ReasonMissingCounter {
+		t.Fatalf("expected missing counter, got %+v", res)
+	}
+}
+
+func TestKeyQRCode(t *testing.T) {
+	key, err := GenerateKeyObject(&CreateOtpCmd{Issuer: "ACME", AccountName: "alice", OtpType: TOTP, EncSecret: "JBSWY3DPEHPK3PXP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := key.PNG(qrcode.Options{Level: qrcode.Medium})
+	if err != nil || !bytes.HasPrefix(b, []byte("\x89PNG")) {
+		t.Fatalf("PNG = %d bytes, %v", len(b), err)
+	}
+	if _, err = key.SVG(qrcode.Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if s, err := key.Terminal(qrcode.Options{Margin: 2}); err != nil || !strings.Contains(s, "█") {
+		t.Fatalf("Terminal = %q, %v", s, err)
+	}
+}
+
+func TestTypedRequest(t *testing.T) {
+	hCmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890"}
+	code, err := GenerateCodeWith(hCmd, Request{Counter: 0})
+	if err != nil || code != "755224" {
+		t.Fatalf("GenerateCodeWith = %q, %v; want 755224", code, err)
+	}
+	// plain integers are accepted as counters
+	if code, err = GenerateCode(hCmd, 5); err != nil || code != "254676" {
+		t.Fatalf("GenerateCode = %q, %v; want 254676", code, err)
+	}
+	if _, err = GenerateCode(hCmd, -1); err == nil {
+		t.Fatal("expected a negative counter to fail")
+	}
+	if _, err = GenerateCode(hCmd, 1.5); err == nil {
+		t.Fatal("expected an unsupported parameter type to fail")
+	}
+
+	tCmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8}
+	if !ValidateWith(tCmd, "89005924", Request{Time: time.Unix(1234567890, 0)}) {
+		t.Fatal("expected ValidateWith to use req.Time")
+	}
+
+	// mobile without a PIN reports an error instead of panicking
+	mCmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Pattern: enum.Mobile}
+	if _, err = GenerateCode(mCmd); err == nil {
+		t.Fatal("expected missing pin error")
+	}
+	if res := VerifyWith(mCmd, "abcdef", Request{}); res.Reason != enum.ReasonMissingPIN {
+		t.Fatalf("expected missing pin, got %+v", res)
+	}
+	code, err = GenerateCodeWith(mCmd, Request{PIN: "6688", Time: time.Unix(1234567890, 0)})
+	if err != nil || !ValidateAt(mCmd, code, time.Unix(1234567890, 0), "6688") {
+		t.Fatalf("mobile round trip failed: %q, %v", code, err)
+	}
+}
+
+func TestPatternRegistry(t *testing.T) {
+	if err := AddOtpPattern([]Aop{{PatternName: enum.Mobile, Pattern: &MobilePattern{}}}); !errors.Is(err, pattern.ErrBuiltin) {
+		t.Fatalf("overwriting a built-in pattern = %v; want ErrBuiltin", err)
+	}
+	if err := pattern.Default().Unregister(enum.Standard); !errors.Is(err, pattern.ErrBuiltin) {
+		t.Fatalf("removing a built-in pattern = %v; want ErrBuiltin", err)
+	}
+
+	// A private registry does not leak into the default one
+	reg := pattern.NewRegistry()
+	if err := reg.Register("letters", &MobilePattern{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pattern.Default().Lookup("letters"); ok {
+		t.Fatal("private pattern found in the default registry")
+	}
+	if got := reg.List(); len(got) != 4 || got[0] != "letters" {
+		t.Fatalf("List = %v", got)
+	}
+
+	cmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890", Pattern: "letters", Registry: reg}
+	code, err := GenerateCode(cmd, uint64(1))
+	if err != nil || len(code) != 6 {
+		t.Fatalf("GenerateCode = %q, %v", code, err)
+	}
+	cmd.Registry = nil
+	if _, err = GenerateCode(cmd, uint64(1)); err == nil {
+		t.Fatal("unregistered pattern accepted by the default registry")
+	}
+
+	if err = reg.Unregister("letters"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := reg.Lookup("letters"); ok {
+		t.Fatal("pattern still registered")
+	}
+
+	// Concurrent registration and lookup
+	done := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		go func(i int) {
+			name := enum.PatternEnum(fmt.Sprintf("p%d", i))
+			_ = reg.Register(name, &MobilePattern{})
+			reg.Lookup(name)
+			reg.List()
+			done <- struct{}{}
+		}(i)
+	}
+	for i := 0; i < 8; i++ {
+		<-done
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	hCmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890"}
+	tCmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890"}
+
+	var initErr *InitError
+	_, err := NewOtpInstance(&CreateOtpCmd{OtpType: TOTP, EncSecret: "not base32!"})
+	if !errors.Is(err, ErrSecretDecode) || !errors.As(err, &initErr) || initErr.OtpType != "TOTP" {
+		t.Fatalf("bad secret = %v; want InitError wrapping ErrSecretDecode", err)
+	}
+	if _, err = NewOtpInstance(&CreateOtpCmd{OtpType: "sotp"}); !errors.Is(err, ErrUnsupportedOtpType) {
+		t.Fatalf("bad type = %v; want ErrUnsupportedOtpType", err)
+	}
+	if _, err = GenerateCode(&CreateOtpCmd{OtpType: HOTP, Secret: "x", Pattern: "nope"}, uint64(1)); !errors.Is(err, ErrUnknownPattern) {
+		t.Fatalf("unknown pattern = %v; want ErrUnknownPattern", err)
+	}
+	if _, err = GenerateCode(hCmd); !errors.Is(err, ErrMissingCounter) {
+		t.Fatalf("no counter = %v; want ErrMissingCounter", err)
+	}
+	if _, err = GenerateCode(hCmd, 1.5); !errors.Is(err, ErrInvalidParameter) {
+		t.Fatalf("float counter = %v; want ErrInvalidParameter", err)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"hotp valid", ValidateErr(hCmd, "287082", uint64(1)), nil},
+		{"hotp mismatch", ValidateErr(hCmd, "000000", uint64(1)), ErrInvalidCode},
+		{"hotp length", ValidateErr(hCmd, "28708", uint64(1)), ErrWrongLength},
+		{"totp mismatch", ValidateWithErr(tCmd, "000000", Request{Time: time.Unix(59, 0)}), ErrInvalidCode},
+		{"totp length", ValidateWithErr(tCmd, "0", Request{Time: time.Unix(59, 0)}), ErrWrongLength},
+		{"mobile pin", ValidateErr(&CreateOtpCmd{OtpType: TOTP, Secret: "x", Pattern: enum.Mobile}, "aaaaaa"), ErrMissingPIN},
+		{"resync", func() error { _, err := Resync(hCmd, "000000", "000000", 0); return err }(), ErrResyncFailed},
+	}
+	for _, tt := range tests {
+		if tt.want == nil && tt.err != nil || tt.want != nil && !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: err = %v; want %v", tt.name, tt.err, tt.want)
+		}
+	}
+
+	store := replay.NewMemoryStore()
+	hCmd.ReplayStore = store
+	_ = ValidateErr(hCmd, "287082", uint64(1))
+	if err = ValidateErr(hCmd, "287082", uint64(1)); !errors.Is(err, ErrReplay) {
+		t.Fatalf("reused code = %v; want ErrReplay", err)
+	}
+}
+
+func TestMismatchContract(t *testing.T) {
+	req := Request{Counter: 1, Time: time.Unix(59, 0)}
+	for _, otpType := range []TypeEnum{HOTP, TOTP} {
+		cmd := &CreateOtpCmd{OtpType: otpType, Secret: "12345678901234567890"}
+		obj, err := NewOtpInstance(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v, err := NewVerifier(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, validate := range map[string]func(string) (bool, error){
+			"instance": func(code string) (bool, error) { return obj.ValidateWith(code, req) },
+			"verifier": func(code string) (bool, error) { return v.ValidateWith(code, req) },
+		} {
+			if ok, err := validate("000000"); ok || err != nil {
+				t.Errorf("%s %s mismatch = %v, %v; want false, nil", otpType, name, ok, err)
+			}
+			if ok, err := validate("0"); ok || !errors.Is(err, ErrWrongLength) {
+				t.Errorf("%s %s length = %v, %v; want ErrWrongLength", otpType, name, ok, err)
+			}
+		}
+		if err = v.VerifyWith("000000", req).AsError(); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("%s verifier AsError = %v; want ErrInvalidCode", otpType, err)
+		}
+	}
+}
+
+func TestDigitLengths(t *testing.T) {
+	// RFC 4226 Appendix D truncated values, reduced to 7, 9 and 10 digits
+	tests := []struct {
+		counter uint64
+		digits  int
+		want    string
+	}{
+		{0, 7, "4755224"},
+		{0, 9, "284755224"},
+		{0, 10, "1284755224"},
+		{1, 7, "4287082"},
+		{1, 9, "094287082"},
+		{1, 10, "1094287082"},
+		{2, 7, "7359152"},
+		{2, 9, "137359152"},
+		{2, 10, "0137359152"},
+		{9, 10, "0645520489"},
+	}
+	for _, tt := range tests {
+		cmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890", Digits: tt.digits}
+		h, err := hotp.NewHOtp(&command.CreateOtpCmd{Secret: cmd.Secret, Digits: enum.DigitEnum(tt.digits)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		code, err := h.GenerateCodeForCounter(tt.counter)
+		if err != nil || code != tt.want {
+			t.Errorf("counter %d, %d digits = %q, %v; want %s", tt.counter, tt.digits, code, err, tt.want)
+		}
+		if tt.counter > 0 && !Validate(cmd, tt.want, tt.counter) {
+			t.Errorf("counter %d, %d digits: %s rejected", tt.counter, tt.digits, tt.want)
+		}
+	}
+
+	// RFC 6238 Appendix B, SHA1 at T=1111111109 truncated to 10 digits
+	code, err := GenerateCodeAt(&CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 10}, time.Unix(1111111109, 0))
+	if err != nil || len(code) != 10 || !strings.HasSuffix(code, "07081804") {
+		t.Fatalf("10-digit TOTP = %q, %v", code, err)
+	}
+
+	for _, d := range []int{-1, 11, 20} {
+		for _, typ := range []TypeEnum{HOTP, TOTP} {
+			if _, err = NewOtpInstance(&CreateOtpCmd{OtpType: typ, Secret: "x", Digits: d}); !errors.Is(err, ErrInvalidDigits) {
+				t.Errorf("%s with %d digits = %v; want ErrInvalidDigits", typ, d, err)
+			}
+		}
+	}
+}
+
+func TestUnicodeAlphabet(t *testing.T) {
+	reg := pattern.NewRegistry()
+	if err := reg.RegisterAlphabet("greek", "αβγδεζηθικ", pattern.BigEndian); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Unix(59, 0)
+	for _, cmd := range []*CreateOtpCmd{
+		{OtpType: HOTP, Secret: "12345678901234567890", Pattern: "greek", Registry: reg},
+		{OtpType: TOTP, Secret: "12345678901234567890", Pattern: "greek", Registry: reg, Clock: totp.NewFakeClock(at)},
+	} {
+		req := Request{Counter: 1, Time: at}
+		code, err := GenerateCodeWith(cmd, req)
+		if err != nil || len([]rune(code)) != 6 || len(code) != 12 {
+			t.Fatalf("%s code = %q, %v", cmd.OtpType, code, err)
+		}
+		if !ValidateWith(cmd, code, req) {
+			t.Fatalf("%s rejected its own code %q", cmd.OtpType, code)
+		}
+		v, err := NewVerifier(cmd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res := v.VerifyWith(code, req); !res.Valid {
+			t.Fatalf("%s verifier = %+v", cmd.OtpType, res)
+		}
+		if res := v.VerifyWith(string([]rune(code)[:5]), req); res.Reason != enum.ReasonWrongLength {
+			t.Fatalf("%s short code = %+v", cmd.OtpType, res)
+		}
+	}
+}
+
+func TestAlphabetPattern(t *testing.T) {
+	if _, err := pattern.NewAlphabetPattern("AA", pattern.BigEndian); err == nil {
+		t.Fatal("duplicate characters accepted")
+	}
+	if _, err := pattern.NewAlphabetPattern("A", pattern.BigEndian); err == nil {
+		t.Fatal("single character alphabet accepted")
+	}
+
+	reg := pattern.NewRegistry()
+	if err := reg.RegisterAlphabet("steamlike", pattern.SteamChars, pattern.LittleEndian); err != nil {
+		t.Fatal(err)
+	}
+	if err := reg.RegisterAlphabet("hexcode", pattern.Hex, pattern.BigEndian); err != nil {
+		t.Fatal(err)
+	}
+	at := time.Unix(1234567890, 0)
+
+	// The steam alphabet in little endian order reproduces the steam pattern
+	steam, err := GenerateCodeAt(&CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Pattern: enum.Steam}, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	same, err := GenerateCodeAt(&CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Pattern: "steamlike", Digits: 5, Registry: reg}, at)
+	if err != nil || same != steam {
+		t.Fatalf("steamlike = %q, %v; want %q", same, err, steam)
+	}
+
+	// 1094287082 (RFC 4226, counter 1) is 0x41397EEA
+	code, err := GenerateCode(&CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890", Pattern: "hexcode", Digits: 8, Registry: reg}, uint64(1))
+	if err != nil || code != "41397EEA" {
+		t.Fatalf("hex code = %q, %v; want 41397EEA", code, err)
+	}
+
+	// Keys of a registered alphabet carry its name as host and round trip through ParseKey
+	cmd := &CreateOtpCmd{OtpType: TOTP, Issuer: "Example", AccountName: "alice", Secret: "12345678901234567890", Pattern: "hexcode", Registry: reg}
+	key, err := GenerateKeyObject(cmd)
+	if err != nil || key.Host() != "hexcode" || key.Pattern() != "hexcode" {
+		t.Fatalf("key = %v, %v", key, err)
+	}
+	parsed := key.CreateOtpCmd()
+	parsed.Registry = reg
+	code, err = GenerateCodeAt(parsed, at)
+	if err != nil || len(code) != 6 || !ValidateAt(cmd, code, at) {
+		t.Fatalf("parsed key code = %q, %v", code, err)
+	}
+}
+
+func TestOptionConstructors(t *testing.T) {
+	tObj, err := totp.New(
+		totp.WithSecret([]byte("12345678901234567890")),
+		totp.WithDigits(enum.DigitEight),
+		totp.WithAlgorithm(enum.AlgorithmSHA1),
+		totp.WithPeriod(30),
+		totp.WithSkew(1),
+		totp.WithClock(totp.NewFakeClock(time.Unix(1111111109, 0))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, err := tObj.GenerateCode(); err != nil || code[0] != "07081804" {
+		t.Fatalf("totp.New code = %v, %v; want 07081804", code, err)
+	}
+
+	hObj, err := hotp.New(hotp.WithEncSecret("GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"), hotp.WithLookAhead(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := hObj.VerifyCounter("359152", 0, ""); !res.Valid || res.Counter != 2 {
+		t.Fatalf("hotp.New look-ahead = %+v", res)
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"unknown algorithm", func() error { _, err := totp.New(totp.WithAlgorithm(9)); return err }(), ErrInvalidAlgorithm},
+		{"zero period", func() error { _, err := totp.New(totp.WithPeriod(0)); return err }(), ErrInvalidPeriod},
+		{"too many digits", func() error { _, err := hotp.New(hotp.WithDigits(11)); return err }(), ErrInvalidDigits},
+		{"bad secret", func() error { _, err := hotp.New(hotp.WithEncSecret("1!")); return err }(), ErrSecretDecode},
+		{"empty secret", func() error { _, err := hotp.New(hotp.WithSecret(nil)); return err }(), ErrInvalidOption},
+		{"nil clock", func() error { _, err := totp.New(totp.WithClock(nil)); return err }(), ErrInvalidOption},
+		{"unknown pattern", func() error { _, err := totp.New(totp.WithPattern("nope")); return err }(), ErrUnknownPattern},
+		{"cmd algorithm", (&CreateOtpCmd{OtpType: HOTP, Algorithm: -1}).Validate(), ErrInvalidAlgorithm},
+		{"cmd type", (&CreateOtpCmd{OtpType: "sotp"}).Validate(), ErrUnsupportedOtpType},
+		{"cmd pattern", (&CreateOtpCmd{OtpType: TOTP, Pattern: "nope"}).Validate(), ErrUnknownPattern},
+		{"cmd valid", (&CreateOtpCmd{OtpType: TOTP, Period: 60, Digits: 8, EncSecret: "MRUGYYLOONUGC3Q"}).Validate(), nil},
+	}
+	for _, tt := range tests {
+		if tt.want == nil && tt.err != nil || tt.want != nil && !errors.Is(tt.err, tt.want) {
+			t.Errorf("%s: err = %v; want %v", tt.name, tt.err, tt.want)
+		}
+	}
+	var initErr *InitError
+	if _, err = totp.New(totp.WithPeriod(0)); !errors.As(err, &initErr) || initErr.OtpType != "TOTP" {
+		t.Fatalf("option error = %v; want InitError", err)
+	}
+}
+
+func TestCodeInfo(t *testing.T) {
+	clock := totp.NewFakeClock(time.Unix(1111111109, 500_000_000))
+	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8, Clock: clock}
+	info, err := GenerateCodeInfo(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1111111109 is in step 37037036, [1111111080, 1111111110)
+	if info.Code != "07081804" || info.Counter != 37037036 ||
+		info.Start.Unix() != 1111111080 || info.End.Unix() != 1111111110 || info.Remaining != 500*time.Millisecond {
+		t.Fatalf("info = %+v", info)
+	}
+
+	if _, err = GenerateCodeInfo(&CreateOtpCmd{OtpType: HOTP, Secret: "x"}, uint64(1)); !errors.Is(err, ErrUnsupportedOtpType) {
+		t.Fatalf("HOTP = %v; want ErrUnsupportedOtpType", err)
+	}
+}
+
+func TestSealedSecret(t *testing.T) {
+	kek, _ := sealed.GenerateKey()
+	provider, err := sealed.NewLocalProvider("k1", map[string][]byte{"k1": kek})
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := []byte("12345678901234567890")
+	sealedSecret, err := sealed.Seal(provider, secret, []byte("record-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hObj, err := hotp.NewHOtp(&command.CreateOtpCmd{SealedSecret: sealedSecret, KeyProvider: provider, AssociatedData: []byte("record-1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(hObj.Secret, secret) {
+		t.Fatalf("hotp secret = %q", hObj.Secret)
+	}
+	if code, _ := hObj.GenerateCodeForCounter(0); code != "755224" {
+		t.Fatalf("hotp code = %s", code)
+	}
+
+	tObj, err := NewOtpInstance(&CreateOtpCmd{OtpType: TOTP, SealedSecret: sealedSecret, KeyProvider: provider, AssociatedData: []byte("record-1"), Digits: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if codes, _ := tObj.GenerateCode(time.Unix(59, 0)); codes[0] != "94287082" {
+		t.Fatalf("totp code = %v", codes)
+	}
+
+	// secrets sealed by an instance open after a KEK rotation
+	stored, err := hObj.SealSecret(provider, []byte("record-2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _ := sealed.GenerateKey()
+	if err = provider.Rotate("k2", next); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = totp.New(totp.WithSealedSecret(stored, provider, []byte("record-2"))); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = hotp.NewHOtp(&command.CreateOtpCmd{SealedSecret: sealedSecret}); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("missing provider = %v", err)
+	}
+	other, _ := sealed.NewLocalProvider("k1", map[string][]byte{"k1": next})
+	if _, err = totp.New(totp.WithSealedSecret(sealedSecret, other, []byte("record-1"))); !errors.Is(err, ErrSecretDecode) {
+		t.Fatalf("wrong provider = %v", err)
+	}
+	if _, err = totp.New(totp.WithSealedSecret(stored, provider, []byte("record-1"))); !errors.Is(err, ErrSecretDecode) {
+		t.Fatalf("secret of another record = %v", err)
+	}
+	empty, _ := sealed.Seal(provider, nil, nil)
+	if _, err = hotp.New(hotp.WithSealedSecret(empty, provider, nil)); !errors.Is(err, ErrSecretDecode) || strings.Contains(err.Error(), "%!") {
+		t.Fatalf("empty secret = %v", err)
+	}
+	if err = (&CreateOtpCmd{OtpType: HOTP, SealedSecret: sealedSecret}).Validate(); !errors.Is(err, ErrInvalidOption) {
+		t.Fatalf("Validate without provider = %v", err)
+	}
 }
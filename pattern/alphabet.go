@@ -0,0 +1,41 @@
+package pattern
+
+import (
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/realize"
+)
+
+// Order the position of the least significant character in an alphabet code
+type Order = realize.OrderEnum
+
+const (
+	LittleEndian = realize.LittleEndian // least significant character first, like steam
+	BigEndian    = realize.BigEndian    // most significant character first, like mobile and decimal codes
+)
+
+// Common alphabets
+const (
+	Crockford   = "0123456789ABCDEFGHJKMNPQRSTVWXYZ" // Crockford base32, without I, L, O and U
+	Unambiguous = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // uppercase and digits, without 0, 1, I and O
+	Hex         = "0123456789ABCDEF"
+	SteamChars  = "23456789BCDFGHJKMNPQRTVWXY"
+)
+
+// AlphabetPattern encodes codes with the characters of an arbitrary alphabet
+type AlphabetPattern = realize.AlphabetPattern
+
+// NewAlphabetPattern the pattern of alphabet, which needs at least two distinct characters
+func NewAlphabetPattern(alphabet string, order Order) (*AlphabetPattern, error) {
+	return realize.NewAlphabetPattern(alphabet, order)
+}
+
+// RegisterAlphabet register the alphabet pattern under name, which is also the otpauth uri host
+// of its keys. Key hosts are case-insensitive, so use a lowercase name.
+func (r *Registry) RegisterAlphabet(name string, alphabet string, order Order) error {
+	p, err := NewAlphabetPattern(alphabet, order)
+	if err != nil {
+		return err
+	}
+
+	return r.Register(enum.PatternEnum(name), p)
+}
@@ -0,0 +1,107 @@
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/realize"
+	"sort"
+	"sync"
+)
+
+// Pattern customizes how the counter is built and how the HMAC value becomes a code
+type Pattern = abstract.Pattern
+
+// ErrBuiltin built-in patterns cannot be replaced or removed without Override
+var ErrBuiltin = errors.New("built-in pattern cannot be modified")
+
+// Registry a set of named patterns, safe for concurrent use
+type Registry struct {
+	mu       sync.RWMutex
+	patterns map[enum.PatternEnum]Pattern
+	builtin  map[enum.PatternEnum]bool
+}
+
+var defaultRegistry = NewRegistry()
+
+// Default the registry used by HOtp and TOtp when none is configured
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// NewRegistry returns a registry holding the built-in patterns
+func NewRegistry() *Registry {
+	r := &Registry{patterns: map[enum.PatternEnum]Pattern{}, builtin: map[enum.PatternEnum]bool{}}
+	for name, p := range map[enum.PatternEnum]Pattern{
+		enum.Standard: &realize.StandardPattern{},
+		enum.Steam:    &realize.SteamPattern{},
+		enum.Mobile:   &realize.MobilePattern{},
+	} {
+		r.patterns[name] = p
+		r.builtin[name] = true
+	}
+	return r
+}
+
+// Register add or replace the pattern name. Built-in patterns are rejected with ErrBuiltin
+func (r *Registry) Register(name enum.PatternEnum, p Pattern) error {
+	return r.register(name, p, false)
+}
+
+// Override add or replace the pattern name, including built-in ones
+func (r *Registry) Override(name enum.PatternEnum, p Pattern) error {
+	return r.register(name, p, true)
+}
+
+func (r *Registry) register(name enum.PatternEnum, p Pattern, override bool) error {
+	if name == "" || p == nil {
+		return errors.New("pattern name and implementation are required")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.builtin[name] && !override {
+		return fmt.Errorf("%w: %s", ErrBuiltin, name)
+	}
+	r.patterns[name] = p
+
+	return nil
+}
+
+// Unregister remove the pattern name. Built-in patterns are rejected with ErrBuiltin
+func (r *Registry) Unregister(name enum.PatternEnum) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.builtin[name] {
+		return fmt.Errorf("%w: %s", ErrBuiltin, name)
+	}
+	delete(r.patterns, name)
+
+	return nil
+}
+
+// Lookup the pattern registered as name
+func (r *Registry) Lookup(name enum.PatternEnum) (Pattern, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.patterns[name]
+	return p, ok
+}
+
+// List the registered pattern names in sorted order
+func (r *Registry) List() []enum.PatternEnum {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]enum.PatternEnum, 0, len(r.patterns))
+	for name := range r.patterns {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+
+	return names
+}
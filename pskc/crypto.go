@@ -0,0 +1,135 @@
+package pskc
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+// Encryption and MAC algorithms
+const (
+	AlgorithmAES128CBC  = "http://www.w3.org/2001/04/xmlenc#aes128-cbc"
+	AlgorithmAES192CBC  = "http://www.w3.org/2001/04/xmlenc#aes192-cbc"
+	AlgorithmAES256CBC  = "http://www.w3.org/2001/04/xmlenc#aes256-cbc"
+	AlgorithmHMACSHA1   = "http://www.w3.org/2000/09/xmldsig#hmac-sha1"
+	AlgorithmHMACSHA256 = "http://www.w3.org/2001/04/xmldsig-more#hmac-sha256"
+)
+
+// aesKeySize the key size of an AES-CBC algorithm
+func aesKeySize(algorithm string) (int, error) {
+	switch algorithm {
+	case AlgorithmAES128CBC:
+		return 16, nil
+	case AlgorithmAES192CBC:
+		return 24, nil
+	case AlgorithmAES256CBC:
+		return 32, nil
+	}
+	return 0, fmt.Errorf("%w: encryption algorithm %q", ErrUnsupported, algorithm)
+}
+
+// macHash the hash of an HMAC algorithm
+func macHash(algorithm string) (func() hash.Hash, error) {
+	switch algorithm {
+	case AlgorithmHMACSHA1:
+		return sha1.New, nil
+	case AlgorithmHMACSHA256:
+		return sha256.New, nil
+	}
+	return nil, fmt.Errorf("%w: MAC algorithm %q", ErrUnsupported, algorithm)
+}
+
+// decrypt the base64 IV and ciphertext of an AES-CBC encrypted value, removing the PKCS #7 padding
+func decrypt(key []byte, data *xmlEncryptedData) ([]byte, error) {
+	size, err := aesKeySize(data.EncryptionMethod.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("%w: %d byte key for %s", ErrDecrypt, len(key), data.EncryptionMethod.Algorithm)
+	}
+	raw, err := decodeBase64(data.CipherData.CipherValue)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 2*aes.BlockSize || len(raw)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: invalid ciphertext length %d", ErrDecrypt, len(raw))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, len(raw)-aes.BlockSize)
+	cipher.NewCBCDecrypter(block, raw[:aes.BlockSize]).CryptBlocks(out, raw[aes.BlockSize:])
+
+	n := int(out[len(out)-1])
+	if n == 0 || n > aes.BlockSize || !bytes.Equal(out[len(out)-n:], bytes.Repeat([]byte{byte(n)}, n)) {
+		return nil, fmt.Errorf("%w: invalid padding, wrong key?", ErrDecrypt)
+	}
+
+	return out[:len(out)-n], nil
+}
+
+// encrypt plain with AES-CBC under a random IV
+func encrypt(key, plain []byte, rand io.Reader) (*xmlEncryptedData, error) {
+	algorithm := AlgorithmAES128CBC
+	switch len(key) {
+	case 16:
+	case 24:
+		algorithm = AlgorithmAES192CBC
+	case 32:
+		algorithm = AlgorithmAES256CBC
+	default:
+		return nil, fmt.Errorf("invalid AES key length %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	n := aes.BlockSize - len(plain)%aes.BlockSize
+	padded := append(append([]byte(nil), plain...), bytes.Repeat([]byte{byte(n)}, n)...)
+	raw := make([]byte, aes.BlockSize+len(padded))
+	if _, err = io.ReadFull(rand, raw[:aes.BlockSize]); err != nil {
+		return nil, err
+	}
+	cipher.NewCBCEncrypter(block, raw[:aes.BlockSize]).CryptBlocks(raw[aes.BlockSize:], padded)
+
+	return &xmlEncryptedData{
+		EncryptionMethod: xmlAlgorithm{Algorithm: algorithm},
+		CipherData:       xmlCipherData{CipherValue: base64.StdEncoding.EncodeToString(raw)},
+	}, nil
+}
+
+// valueMAC the MAC of an encrypted value, computed over its IV and ciphertext (RFC 6030 §6.1.1)
+func valueMAC(h func() hash.Hash, key []byte, data *xmlEncryptedData) ([]byte, error) {
+	raw, err := decodeBase64(data.CipherData.CipherValue)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(h, key)
+	mac.Write(raw)
+
+	return mac.Sum(nil), nil
+}
+
+// decodeBase64 decode a base64 value, ignoring the white space of pretty-printed documents
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Join(strings.Fields(s), "")
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid base64 value")
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,493 @@
+// Package pskc reads and writes Portable Symmetric Key Containers (RFC 6030), the XML format
+// token vendors use to ship HOTP and TOTP seeds. Plain containers, containers encrypted with a
+// pre-shared AES key and containers encrypted with a PBKDF2 passphrase are supported.
+package pskc
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/internal/util"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Key algorithms of RFC 6030 §10
+const (
+	AlgorithmHOTP = "urn:ietf:params:xml:ns:keyprov:pskc:hotp"
+	AlgorithmTOTP = "urn:ietf:params:xml:ns:keyprov:pskc:totp"
+)
+
+// EncodingDecimal the response encoding of numeric codes
+const EncodingDecimal = "DECIMAL"
+
+// DefaultIterations the PBKDF2 iteration count of Marshal
+const DefaultIterations = 10000
+
+// MaxIterations the largest PBKDF2 iteration count accepted from a document
+const MaxIterations = 1000000
+
+var (
+	ErrUnsupported = errors.New("unsupported PSKC feature")
+	ErrNoKey       = errors.New("container is encrypted, a pre-shared key or password is required")
+	ErrDecrypt     = errors.New("decryption failed")
+	ErrMAC         = errors.New("value MAC does not match")
+)
+
+// Container a key container
+type Container struct {
+	ID   string
+	Keys []*Key
+}
+
+// Key a key package, the secret and parameters of one token
+type Key struct {
+	ID            string             // The key identifier, unique within the container
+	Algorithm     string             // AlgorithmHOTP or AlgorithmTOTP
+	Issuer        string             // The name of the issuer/company
+	FriendlyName  string             // A display name
+	Manufacturer  string             // The token manufacturer
+	SerialNo      string             // The token serial number
+	Model         string             // The token model
+	UserID        string             // The user the key is assigned to
+	Secret        []byte             // The raw secret key
+	Digits        int                // The code length. Default is 6
+	Encoding      string             // The code encoding, only EncodingDecimal is usable by otp
+	HashAlgorithm enum.AlgorithmEnum // The HMAC algorithm, from the Suite parameter. Default is SHA1
+	Counter       uint64             // The HOTP counter
+	Time          int64              // The TOTP time value, in unix seconds
+	TimeInterval  uint               // The TOTP period in seconds
+	TimeDrift     int64              // The TOTP drift in steps
+}
+
+// Options the keys protecting a container. Parse uses whichever the container requires,
+// Marshal encrypts with PreSharedKey or Password and writes a plain container when both are empty.
+type Options struct {
+	PreSharedKey []byte    // AES-128, 192 or 256 key
+	KeyName      string    // The name of PreSharedKey written by Marshal
+	Password     string    // PBKDF2 passphrase
+	Iterations   int       // PBKDF2 iteration count of Marshal. Default is DefaultIterations
+	Rand         io.Reader // The source of IVs, salts and MAC keys. Default is crypto/rand
+}
+
+// Decode read and parse a container
+func Decode(r io.Reader, opts Options) (*Container, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return Parse(data, opts)
+}
+
+// Parse a container, decrypting its secrets and checking their MACs
+func Parse(data []byte, opts Options) (*Container, error) {
+	var doc xmlContainer
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid PSKC document: %w", err)
+	}
+	if doc.Version != "1.0" {
+		return nil, fmt.Errorf("%w: version %q", ErrUnsupported, doc.Version)
+	}
+
+	d, err := newDecrypter(&doc, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Container{ID: doc.ID}
+	for i, kp := range doc.KeyPackages {
+		k, err := d.keyPackage(kp)
+		if err != nil {
+			return nil, fmt.Errorf("key package %d: %w", i+1, err)
+		}
+		c.Keys = append(c.Keys, k)
+	}
+
+	return c, nil
+}
+
+// decrypter the encryption and MAC keys of a container
+type decrypter struct {
+	key    []byte
+	macKey []byte
+	mac    string
+}
+
+func newDecrypter(doc *xmlContainer, opts Options) (*decrypter, error) {
+	d := &decrypter{}
+	if ek := doc.EncryptionKey; ek != nil {
+		switch {
+		case ek.DerivedKey != nil:
+			method := ek.DerivedKey.KeyDerivationMethod
+			if method.Algorithm != algPBKDF2 || method.PBKDF2Params == nil {
+				return nil, fmt.Errorf("%w: key derivation %q", ErrUnsupported, method.Algorithm)
+			}
+			if opts.Password == "" {
+				return nil, ErrNoKey
+			}
+			p := method.PBKDF2Params
+			salt, err := decodeBase64(p.Salt.Specified)
+			if err != nil {
+				return nil, fmt.Errorf("invalid PBKDF2 salt: %w", err)
+			}
+			prf := AlgorithmHMACSHA1
+			if p.PRF != nil && p.PRF.Algorithm != "" {
+				prf = p.PRF.Algorithm
+			}
+			h, err := macHash(prf)
+			if err != nil {
+				return nil, err
+			}
+			if p.IterationCount <= 0 || p.IterationCount > MaxIterations {
+				return nil, fmt.Errorf("invalid PBKDF2 iteration count %d, must be between 1 and %d", p.IterationCount, MaxIterations)
+			}
+			// the derived key is an AES key, its size is checked against the algorithm of each value
+			keyLen := p.KeyLength
+			if keyLen == 0 {
+				keyLen = 16
+			}
+			if keyLen != 16 && keyLen != 24 && keyLen != 32 {
+				return nil, fmt.Errorf("invalid PBKDF2 key length %d, must be 16, 24 or 32", keyLen)
+			}
+			d.key = util.PBKDF2(h, []byte(opts.Password), salt, p.IterationCount, keyLen)
+		default:
+			if len(opts.PreSharedKey) == 0 {
+				return nil, ErrNoKey
+			}
+			d.key = opts.PreSharedKey
+		}
+	}
+
+	if mm := doc.MACMethod; mm != nil {
+		if _, err := macHash(mm.Algorithm); err != nil {
+			return nil, err
+		}
+		d.mac = mm.Algorithm
+		// Without MACKey the encryption key is also the MAC key, as in the drafts of RFC 6030
+		d.macKey = d.key
+		if mm.MACKey != nil {
+			if d.key == nil {
+				return nil, ErrNoKey
+			}
+			macKey, err := decrypt(d.key, mm.MACKey)
+			if err != nil {
+				return nil, fmt.Errorf("MAC key: %w", err)
+			}
+			d.macKey = macKey
+		}
+	}
+
+	return d, nil
+}
+
+// value the plain or decrypted bytes of a value
+func (d *decrypter) value(v *xmlValue) ([]byte, error) {
+	if v.EncryptedValue == nil {
+		return decodeBase64(v.PlainValue)
+	}
+	if d.key == nil {
+		return nil, ErrNoKey
+	}
+	if d.mac != "" {
+		if v.ValueMAC == "" {
+			return nil, fmt.Errorf("%w: missing ValueMAC", ErrMAC)
+		}
+		want, err := decodeBase64(v.ValueMAC)
+		if err != nil {
+			return nil, err
+		}
+		h, _ := macHash(d.mac)
+		got, err := valueMAC(h, d.macKey, v.EncryptedValue)
+		if err != nil {
+			return nil, err
+		}
+		if !hmac.Equal(got, want) {
+			return nil, ErrMAC
+		}
+	}
+
+	return decrypt(d.key, v.EncryptedValue)
+}
+
+// integer the plain decimal or decrypted big-endian value of an integer
+func (d *decrypter) integer(v *xmlValue) (int64, error) {
+	if v.EncryptedValue == nil {
+		return strconv.ParseInt(strings.TrimSpace(v.PlainValue), 10, 64)
+	}
+	raw, err := d.value(v)
+	if err != nil {
+		return 0, err
+	}
+	if len(raw) == 0 || len(raw) > 8 {
+		return 0, fmt.Errorf("invalid encrypted integer of %d bytes", len(raw))
+	}
+	var buf [8]byte
+	copy(buf[8-len(raw):], raw)
+
+	return int64(binary.BigEndian.Uint64(buf[:])), nil
+}
+
+func (d *decrypter) keyPackage(kp xmlKeyPackage) (*Key, error) {
+	if kp.Key == nil {
+		return nil, errors.New("missing Key")
+	}
+	x := kp.Key
+	k := &Key{ID: x.ID, Algorithm: x.Algorithm, Issuer: x.Issuer, FriendlyName: x.FriendlyName, UserID: x.UserID, Digits: 6}
+	if di := kp.DeviceInfo; di != nil {
+		k.Manufacturer, k.SerialNo, k.Model = di.Manufacturer, di.SerialNo, di.Model
+		if k.UserID == "" {
+			k.UserID = di.UserID
+		}
+	}
+	if ap := x.AlgorithmParameters; ap != nil {
+		if ap.Suite != "" {
+			alg, err := enum.ParseAlgorithm(strings.TrimPrefix(strings.ToUpper(ap.Suite), "HMAC-"))
+			if err != nil {
+				return nil, fmt.Errorf("%w: suite %q", ErrUnsupported, ap.Suite)
+			}
+			k.HashAlgorithm = alg
+		}
+		if rf := ap.ResponseFormat; rf != nil {
+			k.Digits, k.Encoding = rf.Length, rf.Encoding
+		}
+	}
+
+	data := x.Data
+	if data == nil || data.Secret == nil {
+		return nil, errors.New("missing secret")
+	}
+	var err error
+	if k.Secret, err = d.value(data.Secret); err != nil {
+		return nil, fmt.Errorf("secret: %w", err)
+	}
+
+	for _, f := range []struct {
+		name  string
+		value *xmlValue
+		set   func(int64) error
+	}{
+		{"counter", data.Counter, func(n int64) error { k.Counter = uint64(n); return nonNegative(n) }},
+		{"time", data.Time, func(n int64) error { k.Time = n; return nil }},
+		{"time interval", data.TimeInterval, func(n int64) error { k.TimeInterval = uint(n); return positive(n) }},
+		{"time drift", data.TimeDrift, func(n int64) error { k.TimeDrift = n; return nil }},
+	} {
+		if f.value == nil {
+			continue
+		}
+		n, err := d.integer(f.value)
+		if err == nil {
+			err = f.set(n)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+
+	return k, nil
+}
+
+func nonNegative(n int64) error {
+	if n < 0 {
+		return fmt.Errorf("negative value %d", n)
+	}
+	return nil
+}
+
+func positive(n int64) error {
+	if n <= 0 {
+		return fmt.Errorf("invalid value %d", n)
+	}
+	return nil
+}
+
+// Marshal write the container, encrypting the secrets as configured by opts
+func (c *Container) Marshal(opts Options) ([]byte, error) {
+	if len(opts.PreSharedKey) > 0 && opts.Password != "" {
+		return nil, errors.New("set either a pre-shared key or a password, not both")
+	}
+	if opts.Rand == nil {
+		opts.Rand = rand.Reader
+	}
+	doc := xmlContainer{Version: "1.0", ID: c.ID}
+
+	var key, macKey []byte
+	switch {
+	case len(opts.PreSharedKey) > 0:
+		key = opts.PreSharedKey
+		doc.EncryptionKey = &xmlEncryptionKey{KeyName: opts.KeyName}
+	case opts.Password != "":
+		iterations := opts.Iterations
+		if iterations <= 0 {
+			iterations = DefaultIterations
+		}
+		salt := make([]byte, 16)
+		if _, err := io.ReadFull(opts.Rand, salt); err != nil {
+			return nil, err
+		}
+		key = util.PBKDF2(sha1.New, []byte(opts.Password), salt, iterations, 16)
+		doc.EncryptionKey = &xmlEncryptionKey{DerivedKey: &xmlDerivedKey{
+			KeyDerivationMethod: xmlKeyDerivationMethod{
+				Algorithm: algPBKDF2,
+				PBKDF2Params: &xmlPBKDF2Params{
+					Salt:           xmlSalt{Specified: base64.StdEncoding.EncodeToString(salt)},
+					IterationCount: iterations,
+					KeyLength:      16,
+				},
+			},
+			MasterKeyName: opts.KeyName,
+		}}
+	}
+	if key != nil {
+		macKey = make([]byte, 20)
+		if _, err := io.ReadFull(opts.Rand, macKey); err != nil {
+			return nil, err
+		}
+		encMACKey, err := encrypt(key, macKey, opts.Rand)
+		if err != nil {
+			return nil, err
+		}
+		doc.MACMethod = &xmlMACMethod{Algorithm: AlgorithmHMACSHA1, MACKey: encMACKey}
+	}
+
+	for _, k := range c.Keys {
+		if len(k.Secret) == 0 {
+			return nil, fmt.Errorf("key %q: missing secret", k.ID)
+		}
+		if !k.HashAlgorithm.Valid() {
+			return nil, fmt.Errorf("key %q: %w: %d", k.ID, otp.ErrInvalidAlgorithm, k.HashAlgorithm)
+		}
+		x := &xmlKey{ID: k.ID, Algorithm: k.Algorithm, Issuer: k.Issuer, FriendlyName: k.FriendlyName, UserID: k.UserID, Data: &xmlData{}}
+		ap := &xmlAlgorithmParameters{}
+		if k.HashAlgorithm != enum.AlgorithmSHA1 {
+			ap.Suite = "HMAC-" + k.HashAlgorithm.String()
+		}
+		if k.Digits != 0 {
+			encoding := k.Encoding
+			if encoding == "" {
+				encoding = EncodingDecimal
+			}
+			ap.ResponseFormat = &xmlResponseFormat{Length: k.Digits, Encoding: encoding}
+		}
+		if ap.Suite != "" || ap.ResponseFormat != nil {
+			x.AlgorithmParameters = ap
+		}
+
+		secret := &xmlValue{}
+		if key == nil {
+			secret.PlainValue = base64.StdEncoding.EncodeToString(k.Secret)
+		} else {
+			enc, err := encrypt(key, k.Secret, opts.Rand)
+			if err != nil {
+				return nil, err
+			}
+			mac, err := valueMAC(sha1.New, macKey, enc)
+			if err != nil {
+				return nil, err
+			}
+			secret.EncryptedValue, secret.ValueMAC = enc, base64.StdEncoding.EncodeToString(mac)
+		}
+		x.Data.Secret = secret
+		if k.Algorithm == AlgorithmHOTP || k.Counter != 0 {
+			x.Data.Counter = &xmlValue{PlainValue: strconv.FormatUint(k.Counter, 10)}
+		}
+		if k.Time != 0 {
+			x.Data.Time = &xmlValue{PlainValue: strconv.FormatInt(k.Time, 10)}
+		}
+		if k.TimeInterval != 0 {
+			x.Data.TimeInterval = &xmlValue{PlainValue: strconv.FormatUint(uint64(k.TimeInterval), 10)}
+		}
+		if k.TimeDrift != 0 {
+			x.Data.TimeDrift = &xmlValue{PlainValue: strconv.FormatInt(k.TimeDrift, 10)}
+		}
+
+		kp := xmlKeyPackage{Key: x}
+		if k.Manufacturer != "" || k.SerialNo != "" || k.Model != "" {
+			kp.DeviceInfo = &xmlDeviceInfo{Manufacturer: k.Manufacturer, SerialNo: k.SerialNo, Model: k.Model}
+		}
+		doc.KeyPackages = append(doc.KeyPackages, kp)
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), out...), nil
+}
+
+// CreateOtpCmd the OTP configuration of the key. The account name is the user, serial number
+// or key identifier, whichever is set first.
+func (k *Key) CreateOtpCmd() (*otp.CreateOtpCmd, error) {
+	cmd := &otp.CreateOtpCmd{
+		Issuer:      k.Issuer,
+		AccountName: k.UserID,
+		EncSecret:   common.B32NoPadding.EncodeToString(k.Secret),
+		Digits:      k.Digits,
+		Algorithm:   k.HashAlgorithm,
+	}
+	if cmd.AccountName == "" {
+		cmd.AccountName = k.SerialNo
+	}
+	if cmd.AccountName == "" {
+		cmd.AccountName = k.ID
+	}
+
+	switch k.Algorithm {
+	case AlgorithmHOTP:
+		cmd.OtpType = otp.HOTP
+	case AlgorithmTOTP:
+		cmd.OtpType, cmd.Period = otp.TOTP, k.TimeInterval
+	default:
+		return nil, fmt.Errorf("%w: key algorithm %q", ErrUnsupported, k.Algorithm)
+	}
+	if k.Encoding != "" && k.Encoding != EncodingDecimal {
+		return nil, fmt.Errorf("%w: response encoding %q", ErrUnsupported, k.Encoding)
+	}
+	if err := cmd.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cmd, nil
+}
+
+// NewKey the key package of an OTP configuration, counter is the HOTP counter
+func NewKey(id string, cmd *otp.CreateOtpCmd, counter uint64) (*Key, error) {
+	ok, err := otp.NewKey(cmd, counter)
+	if err != nil {
+		return nil, err
+	}
+	if ok.Pattern() != enum.Standard {
+		return nil, fmt.Errorf("%w: pattern %q", ErrUnsupported, ok.Pattern())
+	}
+	secret, err := util.DecodeBase32Secret(ok.Secret())
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Key{
+		ID:            id,
+		Issuer:        ok.Issuer(),
+		UserID:        ok.AccountName(),
+		Secret:        secret,
+		Digits:        int(ok.Digits()),
+		Encoding:      EncodingDecimal,
+		HashAlgorithm: ok.Algorithm(),
+	}
+	if ok.OtpType() == otp.HOTP {
+		k.Algorithm, k.Counter = AlgorithmHOTP, ok.Counter()
+	} else {
+		k.Algorithm, k.TimeInterval = AlgorithmTOTP, ok.Period()
+	}
+
+	return k, nil
+}
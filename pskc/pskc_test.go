@@ -0,0 +1,358 @@
+package pskc
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"github.com/dhlanshan/otp"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/util"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 6030 Figure 2, a plain HOTP key
+const figure2 = `<?xml version="1.0" encoding="UTF-8"?>
+<KeyContainer Version="1.0" Id="exampleID1" xmlns="urn:ietf:params:xml:ns:keyprov:pskc">
+  <KeyPackage>
+    <Key Id="12345678" Algorithm="urn:ietf:params:xml:ns:keyprov:pskc:hotp">
+      <Issuer>Issuer-A</Issuer>
+      <Data>
+        <Secret>
+          <PlainValue>MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=</PlainValue>
+        </Secret>
+        <Counter>
+          <PlainValue>0</PlainValue>
+        </Counter>
+      </Data>
+    </Key>
+  </KeyPackage>
+</KeyContainer>`
+
+// RFC 6030 Figure 3, supplementary information
+const figure3 = `<?xml version="1.0" encoding="UTF-8"?>
+<KeyContainer Version="1.0" xmlns="urn:ietf:params:xml:ns:keyprov:pskc">
+  <KeyPackage>
+    <DeviceInfo>
+      <Manufacturer>Manufacturer</Manufacturer>
+      <SerialNo>987654321</SerialNo>
+      <UserId>DC=example-bank,DC=net</UserId>
+    </DeviceInfo>
+    <CryptoModuleInfo>
+      <Id>CM_ID_001</Id>
+    </CryptoModuleInfo>
+    <Key Id="12345678" Algorithm="urn:ietf:params:xml:ns:keyprov:pskc:hotp">
+      <Issuer>Issuer</Issuer>
+      <AlgorithmParameters>
+        <ResponseFormat Length="8" Encoding="DECIMAL"/>
+      </AlgorithmParameters>
+      <Data>
+        <Secret>
+          <PlainValue>MTIzNDU2Nzg5MDEyMzQ1Njc4OTA=</PlainValue>
+        </Secret>
+        <Counter>
+          <PlainValue>0</PlainValue>
+        </Counter>
+      </Data>
+      <UserId>UID=jsmith,DC=example-bank,DC=net</UserId>
+    </Key>
+  </KeyPackage>
+</KeyContainer>`
+
+// RFC 6030 Figure 5, AES-128-CBC with the pre-shared key 12345678901234567890123456789012
+const figure5 = `<?xml version="1.0" encoding="UTF-8"?>
+<KeyContainer Version="1.0"
+    xmlns="urn:ietf:params:xml:ns:keyprov:pskc"
+    xmlns:ds="http://www.w3.org/2000/09/xmldsig#"
+    xmlns:xenc="http://www.w3.org/2001/04/xmlenc#">
+    <EncryptionKey>
+        <ds:KeyName>Pre-shared-key</ds:KeyName>
+    </EncryptionKey>
+    <MACMethod Algorithm="http://www.w3.org/2000/09/xmldsig#hmac-sha1">
+        <MACKey>
+            <xenc:EncryptionMethod Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+            <xenc:CipherData>
+                <xenc:CipherValue>
+ESIzRFVmd4iZABEiM0RVZgKn6WjLaTC1sbeBMSvIhRejN9vJa2BOlSaMrR7I5wSX
+                </xenc:CipherValue>
+            </xenc:CipherData>
+        </MACKey>
+    </MACMethod>
+    <KeyPackage>
+        <DeviceInfo>
+            <Manufacturer>Manufacturer</Manufacturer>
+            <SerialNo>987654321</SerialNo>
+        </DeviceInfo>
+        <CryptoModuleInfo>
+            <Id>CM_ID_001</Id>
+        </CryptoModuleInfo>
+        <Key Id="12345678"
+            Algorithm="urn:ietf:params:xml:ns:keyprov:pskc:hotp">
+            <Issuer>Issuer</Issuer>
+            <AlgorithmParameters>
+                <ResponseFormat Length="8" Encoding="DECIMAL"/>
+            </AlgorithmParameters>
+            <Data>
+                <Secret>
+                    <EncryptedValue>
+                        <xenc:EncryptionMethod
+            Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+                        <xenc:CipherData>
+                            <xenc:CipherValue>
+      AAECAwQFBgcICQoLDA0OD+cIHItlB3Wra1DUpxVvOx2lef1VmNPCMl8jwZqIUqGv
+                            </xenc:CipherValue>
+                        </xenc:CipherData>
+                    </EncryptedValue>
+                    <ValueMAC>Su+NvtQfmvfJzF6bmQiJqoLRExc=
+                    </ValueMAC>
+                </Secret>
+                <Counter>
+                    <PlainValue>0</PlainValue>
+                </Counter>
+            </Data>
+        </Key>
+    </KeyPackage>
+</KeyContainer>`
+
+// RFC 6030 Figure 7, PBKDF2 with the password "qwerty"
+const figure7 = `<?xml version="1.0" encoding="UTF-8"?>
+<pskc:KeyContainer
+  xmlns:pskc="urn:ietf:params:xml:ns:keyprov:pskc"
+  xmlns:xenc11="http://www.w3.org/2009/xmlenc11#"
+  xmlns:pkcs5=
+  "http://www.rsasecurity.com/rsalabs/pkcs/schemas/pkcs-5v2-0#"
+  xmlns:xenc="http://www.w3.org/2001/04/xmlenc#" Version="1.0">
+    <pskc:EncryptionKey>
+        <xenc11:DerivedKey>
+            <xenc11:KeyDerivationMethod
+              Algorithm=
+ "http://www.rsasecurity.com/rsalabs/pkcs/schemas/pkcs-5v2-0#pbkdf2">
+                <pkcs5:PBKDF2-params>
+                    <Salt>
+                        <Specified>Ej7/PEpyEpw=</Specified>
+                    </Salt>
+                    <IterationCount>1000</IterationCount>
+                    <KeyLength>16</KeyLength>
+                    <PRF/>
+                </pkcs5:PBKDF2-params>
+            </xenc11:KeyDerivationMethod>
+            <xenc:ReferenceList>
+                <xenc:DataReference URI="#ED"/>
+            </xenc:ReferenceList>
+            <xenc11:MasterKeyName>My Password 1</xenc11:MasterKeyName>
+        </xenc11:DerivedKey>
+    </pskc:EncryptionKey>
+    <pskc:MACMethod
+        Algorithm="http://www.w3.org/2000/09/xmldsig#hmac-sha1">
+        <pskc:MACKey>
+            <xenc:EncryptionMethod
+            Algorithm="http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+            <xenc:CipherData>
+                <xenc:CipherValue>
+  2GTTnLwM3I4e5IO5FkufoOEiOhNj91fhKRQBtBJYluUDsPOLTfUvoU2dStyOwYZx
+                </xenc:CipherValue>
+            </xenc:CipherData>
+        </pskc:MACKey>
+    </pskc:MACMethod>
+    <pskc:KeyPackage>
+        <pskc:DeviceInfo>
+            <pskc:Manufacturer>TokenVendorAcme</pskc:Manufacturer>
+            <pskc:SerialNo>987654321</pskc:SerialNo>
+        </pskc:DeviceInfo>
+        <pskc:CryptoModuleInfo>
+            <pskc:Id>CM_ID_001</pskc:Id>
+        </pskc:CryptoModuleInfo>
+        <pskc:Key Algorithm=
+        "urn:ietf:params:xml:ns:keyprov:pskc:hotp" Id="123456">
+            <pskc:Issuer>Example-Issuer</pskc:Issuer>
+            <pskc:AlgorithmParameters>
+                <pskc:ResponseFormat Length="8" Encoding="DECIMAL"/>
+            </pskc:AlgorithmParameters>
+            <pskc:Data>
+                <pskc:Secret>
+                <pskc:EncryptedValue Id="ED">
+                    <xenc:EncryptionMethod
+                        Algorithm=
+"http://www.w3.org/2001/04/xmlenc#aes128-cbc"/>
+                        <xenc:CipherData>
+                            <xenc:CipherValue>
+      oTvo+S22nsmS2Z/RtcoF8Hfh+jzMe0RkiafpoDpnoZTjPYZu6V+A4aEn032yCr4f
+                        </xenc:CipherValue>
+                    </xenc:CipherData>
+                    </pskc:EncryptedValue>
+                    <pskc:ValueMAC>LP6xMvjtypbfT9PdkJhBZ+D6O4w=
+                    </pskc:ValueMAC>
+                </pskc:Secret>
+            </pskc:Data>
+        </pskc:Key>
+    </pskc:KeyPackage>
+</pskc:KeyContainer>`
+
+var preSharedKey, _ = hex.DecodeString("12345678901234567890123456789012")
+
+// checkRFC4226 the key holds the RFC 4226 secret and generates its vectors
+func checkRFC4226(t *testing.T, k *Key, digits int, want string) {
+	t.Helper()
+	if string(k.Secret) != "12345678901234567890" {
+		t.Fatalf("secret = %q", k.Secret)
+	}
+	if k.Algorithm != AlgorithmHOTP || k.Digits != digits || k.Counter != 0 {
+		t.Fatalf("key = %+v", k)
+	}
+	cmd, err := k.CreateOtpCmd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := otp.GenerateCodeWith(cmd, otp.Request{Counter: k.Counter})
+	if err != nil || code != want {
+		t.Fatalf("code = %q, %v; want %s", code, err, want)
+	}
+}
+
+func TestPlain(t *testing.T) {
+	c, err := Parse([]byte(figure2), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.ID != "exampleID1" || len(c.Keys) != 1 || c.Keys[0].Issuer != "Issuer-A" || c.Keys[0].ID != "12345678" {
+		t.Fatalf("container = %+v, %+v", c, c.Keys)
+	}
+	checkRFC4226(t, c.Keys[0], 6, "755224")
+
+	c, err = Parse([]byte(figure3), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := c.Keys[0]
+	if k.SerialNo != "987654321" || k.Manufacturer != "Manufacturer" || k.UserID != "UID=jsmith,DC=example-bank,DC=net" {
+		t.Fatalf("key = %+v", k)
+	}
+	checkRFC4226(t, k, 8, "84755224")
+	cmd, _ := k.CreateOtpCmd()
+	if cmd.Issuer != "Issuer" || cmd.AccountName != k.UserID {
+		t.Fatalf("cmd = %+v", cmd)
+	}
+}
+
+func TestPreSharedKey(t *testing.T) {
+	c, err := Parse([]byte(figure5), Options{PreSharedKey: preSharedKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRFC4226(t, c.Keys[0], 8, "84755224")
+
+	if _, err = Parse([]byte(figure5), Options{}); !errors.Is(err, ErrNoKey) {
+		t.Fatalf("without key = %v; want ErrNoKey", err)
+	}
+	wrong := bytes.Repeat([]byte{1}, 16)
+	if _, err = Parse([]byte(figure5), Options{PreSharedKey: wrong}); !errors.Is(err, ErrDecrypt) && !errors.Is(err, ErrMAC) {
+		t.Fatalf("wrong key = %v", err)
+	}
+	tampered := strings.Replace(figure5, "Su+NvtQfmvfJzF6bmQiJqoLRExc=", "Su+MvtQfmvfJzF6bmQiJqoLRExc=", 1)
+	if _, err = Parse([]byte(tampered), Options{PreSharedKey: preSharedKey}); !errors.Is(err, ErrMAC) {
+		t.Fatalf("tampered MAC = %v; want ErrMAC", err)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	c, err := Parse([]byte(figure7), Options{Password: "qwerty"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := c.Keys[0]
+	if k.Issuer != "Example-Issuer" || k.Manufacturer != "TokenVendorAcme" || k.ID != "123456" {
+		t.Fatalf("key = %+v", k)
+	}
+	checkRFC4226(t, k, 8, "84755224")
+
+	if _, err = Parse([]byte(figure7), Options{Password: "azerty"}); !errors.Is(err, ErrDecrypt) && !errors.Is(err, ErrMAC) {
+		t.Fatalf("wrong password = %v", err)
+	}
+	if pbkdf2Key := util.PBKDF2(sha1.New, []byte("qwerty"), []byte{0x12, 0x3e, 0xff, 0x3c, 0x4a, 0x72, 0x12, 0x9c}, 1000, 16); hex.EncodeToString(pbkdf2Key) != "651e63cd57008476af1ff6422cd02e41" {
+		t.Fatalf("PBKDF2 = %x", pbkdf2Key)
+	}
+}
+
+func TestPasswordLimits(t *testing.T) {
+	for _, tt := range []struct{ old, new string }{
+		{"<IterationCount>1000<", "<IterationCount>2000000000<"},
+		{"<IterationCount>1000<", "<IterationCount>0<"},
+		{"<KeyLength>16<", "<KeyLength>1073741824<"},
+		{"<KeyLength>16<", "<KeyLength>20<"},
+	} {
+		doc := strings.Replace(figure7, tt.old, tt.new, 1)
+		if _, err := Parse([]byte(doc), Options{Password: "qwerty"}); err == nil {
+			t.Errorf("%s accepted", tt.new)
+		}
+	}
+
+	// a 32 byte key does not decrypt the AES-128 values of the document
+	doc := strings.Replace(figure7, "<KeyLength>16<", "<KeyLength>32<", 1)
+	if _, err := Parse([]byte(doc), Options{Password: "qwerty"}); !errors.Is(err, ErrDecrypt) {
+		t.Fatalf("mismatched key length = %v", err)
+	}
+}
+
+func TestMarshalInvalidAlgorithm(t *testing.T) {
+	c := &Container{Keys: []*Key{{ID: "k1", Algorithm: AlgorithmTOTP, Secret: []byte("12345678901234567890"), HashAlgorithm: 9}}}
+	if _, err := c.Marshal(Options{}); !errors.Is(err, otp.ErrInvalidAlgorithm) {
+		t.Fatalf("Marshal = %v; want ErrInvalidAlgorithm", err)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	hKey, err := NewKey("h1", &otp.CreateOtpCmd{OtpType: otp.HOTP, Issuer: "Example", AccountName: "alice", Secret: "12345678901234567890", Digits: 8}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tCmd := &otp.CreateOtpCmd{OtpType: otp.TOTP, AccountName: "bob", Secret: "12345678901234567890123456789012", Algorithm: enum.AlgorithmSHA256, Period: 60}
+	tKey, err := NewKey("t1", tCmd, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tKey.SerialNo = "SN-1"
+	in := &Container{ID: "export", Keys: []*Key{hKey, tKey}}
+
+	for _, opts := range []Options{{}, {PreSharedKey: preSharedKey, KeyName: "psk"}, {Password: "qwerty", Iterations: 100}} {
+		data, err := in.Marshal(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if encrypted := bytes.Contains(data, []byte("EncryptedValue")); encrypted != (opts.Password != "" || opts.PreSharedKey != nil) {
+			t.Fatalf("encrypted = %v:\n%s", encrypted, data)
+		}
+		out, err := Parse(data, opts)
+		if err != nil {
+			t.Fatalf("%v:\n%s", err, data)
+		}
+		if len(out.Keys) != 2 || out.ID != "export" {
+			t.Fatalf("keys = %+v", out.Keys)
+		}
+
+		h, tk := out.Keys[0], out.Keys[1]
+		if h.Counter != 5 || h.Digits != 8 || h.Issuer != "Example" || h.UserID != "alice" || string(h.Secret) != "12345678901234567890" {
+			t.Fatalf("hotp key = %+v", h)
+		}
+		if tk.Algorithm != AlgorithmTOTP || tk.TimeInterval != 60 || tk.HashAlgorithm != enum.AlgorithmSHA256 || tk.SerialNo != "SN-1" {
+			t.Fatalf("totp key = %+v", tk)
+		}
+
+		// The imported configuration generates the codes of the exported one
+		cmd, err := tk.CreateOtpCmd()
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := otp.Request{Time: time.Unix(1111111109, 0)}
+		got, err := otp.GenerateCodeWith(cmd, req)
+		want, _ := otp.GenerateCodeWith(tCmd, req)
+		if err != nil || got != want {
+			t.Fatalf("totp code = %q, %v; want %s", got, err, want)
+		}
+	}
+
+	if _, err = in.Marshal(Options{PreSharedKey: preSharedKey, Password: "x"}); err == nil {
+		t.Fatal("both keys accepted")
+	}
+}
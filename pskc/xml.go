@@ -0,0 +1,118 @@
+package pskc
+
+import "encoding/xml"
+
+// Namespaces of RFC 6030 and the XML Encryption, Signature and PKCS #5 schemas it uses
+const (
+	nsPSKC    = "urn:ietf:params:xml:ns:keyprov:pskc"
+	nsDS      = "http://www.w3.org/2000/09/xmldsig#"
+	nsXenc    = "http://www.w3.org/2001/04/xmlenc#"
+	nsXenc11  = "http://www.w3.org/2009/xmlenc11#"
+	nsPKCS5   = "http://www.rsasecurity.com/rsalabs/pkcs/schemas/pkcs-5v2-0#"
+	algPBKDF2 = nsPKCS5 + "pbkdf2"
+)
+
+type xmlContainer struct {
+	XMLName       xml.Name          `xml:"urn:ietf:params:xml:ns:keyprov:pskc KeyContainer"`
+	Version       string            `xml:"Version,attr"`
+	ID            string            `xml:"Id,attr,omitempty"`
+	EncryptionKey *xmlEncryptionKey `xml:"EncryptionKey"`
+	MACMethod     *xmlMACMethod     `xml:"MACMethod"`
+	KeyPackages   []xmlKeyPackage   `xml:"KeyPackage"`
+}
+
+type xmlEncryptionKey struct {
+	KeyName    string         `xml:"http://www.w3.org/2000/09/xmldsig# KeyName,omitempty"`
+	DerivedKey *xmlDerivedKey `xml:"http://www.w3.org/2009/xmlenc11# DerivedKey"`
+}
+
+type xmlDerivedKey struct {
+	KeyDerivationMethod xmlKeyDerivationMethod `xml:"http://www.w3.org/2009/xmlenc11# KeyDerivationMethod"`
+	MasterKeyName       string                 `xml:"http://www.w3.org/2009/xmlenc11# MasterKeyName,omitempty"`
+}
+
+type xmlKeyDerivationMethod struct {
+	Algorithm    string           `xml:"Algorithm,attr"`
+	PBKDF2Params *xmlPBKDF2Params `xml:"http://www.rsasecurity.com/rsalabs/pkcs/schemas/pkcs-5v2-0# PBKDF2-params"`
+}
+
+type xmlPBKDF2Params struct {
+	Salt           xmlSalt       `xml:"Salt"`
+	IterationCount int           `xml:"IterationCount"`
+	KeyLength      int           `xml:"KeyLength"`
+	PRF            *xmlAlgorithm `xml:"PRF"`
+}
+
+type xmlSalt struct {
+	Specified string `xml:"Specified"`
+}
+
+type xmlAlgorithm struct {
+	Algorithm string `xml:"Algorithm,attr,omitempty"`
+}
+
+type xmlMACMethod struct {
+	Algorithm string            `xml:"Algorithm,attr"`
+	MACKey    *xmlEncryptedData `xml:"MACKey"`
+}
+
+type xmlEncryptedData struct {
+	ID               string        `xml:"Id,attr,omitempty"`
+	EncryptionMethod xmlAlgorithm  `xml:"http://www.w3.org/2001/04/xmlenc# EncryptionMethod"`
+	CipherData       xmlCipherData `xml:"http://www.w3.org/2001/04/xmlenc# CipherData"`
+}
+
+type xmlCipherData struct {
+	CipherValue string `xml:"http://www.w3.org/2001/04/xmlenc# CipherValue"`
+}
+
+type xmlKeyPackage struct {
+	DeviceInfo       *xmlDeviceInfo       `xml:"DeviceInfo"`
+	CryptoModuleInfo *xmlCryptoModuleInfo `xml:"CryptoModuleInfo"`
+	Key              *xmlKey              `xml:"Key"`
+}
+
+type xmlDeviceInfo struct {
+	Manufacturer string `xml:"Manufacturer,omitempty"`
+	SerialNo     string `xml:"SerialNo,omitempty"`
+	Model        string `xml:"Model,omitempty"`
+	UserID       string `xml:"UserId,omitempty"`
+}
+
+type xmlCryptoModuleInfo struct {
+	ID string `xml:"Id"`
+}
+
+type xmlKey struct {
+	ID                  string                  `xml:"Id,attr"`
+	Algorithm           string                  `xml:"Algorithm,attr"`
+	Issuer              string                  `xml:"Issuer,omitempty"`
+	AlgorithmParameters *xmlAlgorithmParameters `xml:"AlgorithmParameters"`
+	Data                *xmlData                `xml:"Data"`
+	FriendlyName        string                  `xml:"FriendlyName,omitempty"`
+	UserID              string                  `xml:"UserId,omitempty"`
+}
+
+type xmlAlgorithmParameters struct {
+	Suite          string             `xml:"Suite,omitempty"`
+	ResponseFormat *xmlResponseFormat `xml:"ResponseFormat"`
+}
+
+type xmlResponseFormat struct {
+	Length   int    `xml:"Length,attr"`
+	Encoding string `xml:"Encoding,attr"`
+}
+
+type xmlData struct {
+	Secret       *xmlValue `xml:"Secret"`
+	Counter      *xmlValue `xml:"Counter"`
+	Time         *xmlValue `xml:"Time"`
+	TimeInterval *xmlValue `xml:"TimeInterval"`
+	TimeDrift    *xmlValue `xml:"TimeDrift"`
+}
+
+type xmlValue struct {
+	PlainValue     string            `xml:"PlainValue,omitempty"`
+	EncryptedValue *xmlEncryptedData `xml:"EncryptedValue"`
+	ValueMAC       string            `xml:"ValueMAC,omitempty"`
+}
@@ -0,0 +1,109 @@
+package qrcode
+
+import (
+	"bytes"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeVersion(t *testing.T) {
+	for _, tt := range []struct {
+		n     int
+		level Level
+		ver   int
+	}{
+		{17, Low, 1}, {18, Low, 2}, {14, Medium, 1}, {15, Medium, 2}, {7, High, 1},
+		{2953, Low, 40}, {1273, High, 40},
+	} {
+		c, err := Encode(strings.Repeat("a", tt.n), tt.level)
+		if err != nil {
+			t.Fatalf("%d bytes at %s: %v", tt.n, tt.level, err)
+		}
+		if c.Version != tt.ver || c.Size() != tt.ver*4+17 {
+			t.Errorf("%d bytes at %s: version %d; want %d", tt.n, tt.level, c.Version, tt.ver)
+		}
+	}
+	if _, err := Encode(strings.Repeat("a", 2954), Low); err != ErrTooLong {
+		t.Fatalf("expected ErrTooLong, got %v", err)
+	}
+}
+
+func TestFunctionPatterns(t *testing.T) {
+	c, err := Encode("otpauth://totp/ACME:alice?secret=JBSWY3DPEHPK3PXP", Medium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := c.Size()
+	for _, origin := range [][2]int{{0, 0}, {n - 7, 0}, {0, n - 7}} {
+		for i := 0; i < 7; i++ {
+			if !c.Dark(origin[0]+i, origin[1]) || !c.Dark(origin[0], origin[1]+i) || !c.Dark(origin[0]+3, origin[1]+3) {
+				t.Fatalf("missing finder pattern at %v", origin)
+			}
+		}
+	}
+	for i := 8; i < n-8; i++ {
+		if c.Dark(i, 6) != (i%2 == 0) || c.Dark(6, i) != (i%2 == 0) {
+			t.Fatalf("broken timing pattern at %d", i)
+		}
+	}
+	if !c.Dark(8, n-8) {
+		t.Fatal("missing dark module")
+	}
+}
+
+// TestGolden compare every module with symbols decoded by an independent reader, testdata
+// holds one row per line with '#' for dark modules
+func TestGolden(t *testing.T) {
+	const text = "otpauth://totp/ACME:alice?secret=JBSWY3DPEHPK3PXP&issuer=ACME"
+	for _, level := range []Level{Low, Medium, Quartile, High} {
+		want, err := os.ReadFile(filepath.Join("testdata", level.String()+".txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c, err := Encode(text, level)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows := strings.Split(strings.TrimSuffix(string(want), "\n"), "\n")
+		if len(rows) != c.Size() {
+			t.Fatalf("%s: size %d; want %d", level, c.Size(), len(rows))
+		}
+		for y, row := range rows {
+			for x := range row {
+				if c.Dark(x, y) != (row[x] == '#') {
+					t.Fatalf("%s: module (%d, %d) differs from the golden symbol", level, x, y)
+				}
+			}
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	c, err := Encode("hello", Quartile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := c.PNG(Options{Size: 290, Margin: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := img.Bounds().Dx(); w != 290 {
+		t.Fatalf("image width %d; want 290", w)
+	}
+
+	if svg := c.SVG(Options{Size: 100}); !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `viewBox="0 0 29 29"`) {
+		t.Fatalf("unexpected svg %s", svg)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(c.Terminal(Options{Margin: 1}), "\n"), "\n")
+	if len(lines) != 12 || len([]rune(lines[0])) != 23 {
+		t.Fatalf("unexpected terminal output %d lines of %d", len(lines), len([]rune(lines[0])))
+	}
+}
@@ -0,0 +1,136 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+)
+
+// Default rendering options
+const (
+	DefaultSize   = 256 // pixels
+	DefaultMargin = 4   // modules, the quiet zone required by the standard
+)
+
+// Options controls how a Code is encoded and rendered
+type Options struct {
+	Size   int   // The image width in pixels, rounded down to a multiple of the module count. Default is 256
+	Margin int   // The quiet zone in modules. Default is 4, negative means none
+	Level  Level // The error correction level used when encoding. Default is Low
+	Invert bool  // Draw light modules on dark background, for dark terminals
+}
+
+func (o Options) margin() int {
+	if o.Margin == 0 {
+		return DefaultMargin
+	}
+	return max(o.Margin, 0)
+}
+
+// scale the pixels per module for a symbol of n modules including margins
+func (o Options) scale(n int) int {
+	size := o.Size
+	if size == 0 {
+		size = DefaultSize
+	}
+	return max(size/n, 1)
+}
+
+// dark whether the module at x, y of the rendered area is drawn dark
+func (c *Code) dark(x, y, margin int, invert bool) bool {
+	return c.Dark(x-margin, y-margin) != invert
+}
+
+// Image render the code as a grayscale image
+func (c *Code) Image(o Options) image.Image {
+	margin := o.margin()
+	n := c.size + 2*margin
+	scale := o.scale(n)
+
+	img := image.NewGray(image.Rect(0, 0, n*scale, n*scale))
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			v := color.Gray{Y: 0xff}
+			if c.dark(x, y, margin, o.Invert) {
+				v = color.Gray{}
+			}
+			for dy := 0; dy < scale; dy++ {
+				for dx := 0; dx < scale; dx++ {
+					img.SetGray(x*scale+dx, y*scale+dy, v)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+// PNG render the code as a PNG image
+func (c *Code) PNG(o Options) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, c.Image(o)); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG render the code as an SVG document scaled to Size pixels
+func (c *Code) SVG(o Options) string {
+	margin := o.margin()
+	n := c.size + 2*margin
+	size := o.Size
+	if size == 0 {
+		size = DefaultSize
+	}
+	fg, bg := "#000000", "#ffffff"
+	if o.Invert {
+		fg, bg = bg, fg
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, size, size, n, n)
+	fmt.Fprintf(&sb, `<rect width="100%%" height="100%%" fill="%s"/>`, bg)
+	sb.WriteString(`<path d="`)
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				fmt.Fprintf(&sb, "M%d,%dh1v1h-1z", x+margin, y+margin)
+			}
+		}
+	}
+	fmt.Fprintf(&sb, `" fill="%s"/></svg>`, fg)
+
+	return sb.String()
+}
+
+// Terminal render the code with UTF-8 half blocks, two module rows per line.
+// Dark modules are drawn with the foreground colour, set Invert on dark backgrounds.
+func (c *Code) Terminal(o Options) string {
+	margin := o.margin()
+	n := c.size + 2*margin
+
+	var sb strings.Builder
+	for y := 0; y < n; y += 2 {
+		for x := 0; x < n; x++ {
+			top := c.dark(x, y, margin, o.Invert)
+			bottom := y+1 < n && c.dark(x, y+1, margin, o.Invert)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteByte(' ')
+			}
+		}
+		sb.WriteByte('\n')
+	}
+
+	return sb.String()
+}
@@ -0,0 +1,79 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileStore a Store persisted as a JSON file, rewritten atomically after every accepted code
+type FileStore struct {
+	mu      sync.Mutex
+	path    string
+	entries map[string]entry
+}
+
+// NewFileStore open the store at path, creating it on the first write if it does not exist
+func NewFileStore(path string) (*FileStore, error) {
+	f := &FileStore{path: path, entries: map[string]entry{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > 0 {
+		if err = json.Unmarshal(data, &f.entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return f, nil
+}
+
+// Path the file backing the store
+func (f *FileStore) Path() string {
+	return f.path
+}
+
+func (f *FileStore) Accept(key string, counter uint64, ttl time.Duration) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	purge(f.entries, now)
+	if !accept(f.entries, key, counter, ttl, now) {
+		return false, nil
+	}
+	if err := f.save(); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// save write the entries to a temporary file and rename it over the store
+func (f *FileStore) save() error {
+	data, err := json.Marshal(f.entries)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
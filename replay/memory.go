@@ -0,0 +1,32 @@
+package replay
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore an in-memory Store, records expire after their ttl
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]entry
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{entries: map[string]entry{}}
+}
+
+func (m *MemoryStore) Accept(key string, counter uint64, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return accept(m.entries, key, counter, ttl, time.Now()), nil
+}
+
+// Purge drop expired records
+func (m *MemoryStore) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	purge(m.entries, time.Now())
+}
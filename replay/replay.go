@@ -0,0 +1,63 @@
+package replay
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrReplay the dynamic code has already been accepted once
+var ErrReplay = errors.New("dynamic code has already been used")
+
+// Store records the last accepted counter per account (RFC 6238 §5.2)
+type Store interface {
+	// Accept records counter for key if it is greater than the last accepted counter.
+	// It returns false when the counter was already used. A ttl of 0 keeps the record forever.
+	Accept(key string, counter uint64, ttl time.Duration) (bool, error)
+}
+
+// Check record counter for key in store, returning ErrReplay when it was already used
+func Check(store Store, key string, counter uint64, ttl time.Duration) error {
+	ok, err := store.Accept(key, counter, ttl)
+	if err != nil {
+		return fmt.Errorf("replay store failed: %w", err)
+	}
+	if !ok {
+		return ErrReplay
+	}
+
+	return nil
+}
+
+// entry the last accepted counter of an account
+type entry struct {
+	Counter uint64    `json:"counter"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// accept apply the replay rule to entries, reporting whether counter was accepted
+func accept(entries map[string]entry, key string, counter uint64, ttl time.Duration, now time.Time) bool {
+	if e, ok := entries[key]; ok && !e.expired(now) && counter <= e.Counter {
+		return false
+	}
+	e := entry{Counter: counter}
+	if ttl > 0 {
+		e.Expires = now.Add(ttl)
+	}
+	entries[key] = e
+
+	return true
+}
+
+// purge drop expired entries
+func purge(entries map[string]entry, now time.Time) {
+	for k, e := range entries {
+		if e.expired(now) {
+			delete(entries, k)
+		}
+	}
+}
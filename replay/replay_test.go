@@ -0,0 +1,61 @@
+package replay
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	store := NewMemoryStore()
+	if err := Check(store, "alice", 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	for _, counter := range []uint64{5, 4} {
+		if err := Check(store, "alice", counter, 0); !errors.Is(err, ErrReplay) {
+			t.Fatalf("counter %d = %v; want ErrReplay", counter, err)
+		}
+	}
+	if err := Check(store, "alice", 6, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := Check(store, "bob", 1, 0); err != nil {
+		t.Fatalf("another key = %v", err)
+	}
+}
+
+func TestAcceptExpiry(t *testing.T) {
+	entries := map[string]entry{}
+	now := time.Unix(1000, 0)
+	if !accept(entries, "alice", 5, time.Minute, now) {
+		t.Fatal("expected first use to be accepted")
+	}
+	if accept(entries, "alice", 5, time.Minute, now.Add(59*time.Second)) {
+		t.Fatal("expected reuse to be rejected before the record expires")
+	}
+	if !accept(entries, "alice", 5, time.Minute, now.Add(time.Minute)) {
+		t.Fatal("expected the expired record to be replaced")
+	}
+	purge(entries, now.Add(2*time.Minute))
+	if len(entries) != 0 {
+		t.Fatalf("%d entries after purge", len(entries))
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(filepath.Join(t.TempDir(), "replay.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Check(store, "alice", 5, 0); err != nil {
+		t.Fatal(err)
+	}
+	reopened, err := NewFileStore(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Check(reopened, "alice", 5, 0); !errors.Is(err, ErrReplay) {
+		t.Fatalf("reuse after reopening the store = %v; want ErrReplay", err)
+	}
+}
@@ -0,0 +1,217 @@
+package sealed
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/internal/util"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// KeySize the size of a key-encryption key, AES-256
+const KeySize = 32
+
+// PassphraseIterations the PBKDF2-HMAC-SHA256 iteration count of DeriveKey
+const PassphraseIterations = 600000
+
+// LocalProvider a KeyProvider holding its key-encryption keys in memory, optionally persisted to
+// a key file. It is safe for concurrent use.
+type LocalProvider struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+var _ KeyProvider = (*LocalProvider)(nil)
+
+// NewLocalProvider a provider wrapping with keys[current]
+func NewLocalProvider(current string, keys map[string][]byte) (*LocalProvider, error) {
+	p := &LocalProvider{keys: make(map[string][]byte, len(keys))}
+	for id, key := range keys {
+		if err := p.add(id, key); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := p.keys[current]; !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, current)
+	}
+	p.current = current
+
+	return p, nil
+}
+
+// NewPassphraseProvider a provider with the single key derived from passphrase and salt
+func NewPassphraseProvider(id, passphrase string, salt []byte) (*LocalProvider, error) {
+	if passphrase == "" || len(salt) < 8 {
+		return nil, errors.New("passphrase and a salt of at least 8 bytes are required")
+	}
+
+	return NewLocalProvider(id, map[string][]byte{id: DeriveKey(passphrase, salt)})
+}
+
+// DeriveKey derive a key-encryption key from a passphrase with PBKDF2-HMAC-SHA256
+func DeriveKey(passphrase string, salt []byte) []byte {
+	return util.PBKDF2(sha256.New, []byte(passphrase), salt, PassphraseIterations, KeySize)
+}
+
+// GenerateKey a random key-encryption key
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (p *LocalProvider) add(id string, key []byte) error {
+	if id == "" {
+		return errors.New("empty key id")
+	}
+	if len(key) != KeySize {
+		return fmt.Errorf("key %q must be %d bytes", id, KeySize)
+	}
+	p.keys[id] = append([]byte(nil), key...)
+
+	return nil
+}
+
+// Rotate add the key id and make it current. Older keys remain available to Unwrap until
+// Retire, rewrap the stored secrets with Secret.Rewrap in between.
+func (p *LocalProvider) Rotate(id string, key []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.keys[id]; ok {
+		return fmt.Errorf("key %q already exists", id)
+	}
+	if err := p.add(id, key); err != nil {
+		return err
+	}
+	p.current = id
+
+	return nil
+}
+
+// Retire remove a key that no secret is wrapped with anymore
+func (p *LocalProvider) Retire(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id == p.current {
+		return errors.New("the current key cannot be retired")
+	}
+	if _, ok := p.keys[id]; !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+	clear(p.keys[id])
+	delete(p.keys, id)
+
+	return nil
+}
+
+// KeyIDs the identifiers of the available keys, sorted
+func (p *LocalProvider) KeyIDs() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	ids := make([]string, 0, len(p.keys))
+	for id := range p.keys {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	return ids
+}
+
+func (p *LocalProvider) CurrentKeyID() string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.current
+}
+
+func (p *LocalProvider) Wrap(dek []byte) (string, []byte, error) {
+	p.mu.RLock()
+	id, key := p.current, bytes.Clone(p.keys[p.current])
+	p.mu.RUnlock()
+	defer clear(key)
+
+	nonce, ciphertext, err := gcmSeal(key, dek, []byte(id), rand.Reader)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return id, append(nonce, ciphertext...), nil
+}
+
+func (p *LocalProvider) Unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	// copy the key, Retire clears it once the lock is released
+	p.mu.RLock()
+	key, ok := p.keys[keyID]
+	key = bytes.Clone(key)
+	p.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, keyID)
+	}
+	defer clear(key)
+
+	if len(wrapped) < 12 {
+		return nil, ErrInvalid
+	}
+
+	return gcmOpen(key, wrapped[:12], wrapped[12:], []byte(keyID))
+}
+
+// keyFile the JSON form of a LocalProvider
+type keyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string][]byte `json:"keys"`
+}
+
+// LoadKeyFile read a provider saved by Save
+func LoadKeyFile(path string) (*LocalProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err = json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid key file: %w", err)
+	}
+
+	return NewLocalProvider(f.Current, f.Keys)
+}
+
+// Save write the keys to path with mode 0600, replacing the file atomically
+func (p *LocalProvider) Save(path string) error {
+	p.mu.RLock()
+	data, err := json.MarshalIndent(keyFile{Current: p.current, Keys: p.keys}, "", "  ")
+	p.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = tmp.Chmod(0o600); err == nil {
+		_, err = tmp.Write(data)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,197 @@
+// Package sealed encrypts OTP secrets at rest with envelope encryption. Each secret is encrypted
+// with AES-256-GCM under its own data key, which is wrapped by a key-encryption key of a
+// KeyProvider. Rotating the key-encryption key only rewraps the data keys.
+package sealed
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	prefix  = "sealed"
+	version = "v1"
+	dekSize = 32
+)
+
+// additionalData binds the ciphertext to the format version and the caller's associated data
+func additionalData(ad []byte) []byte {
+	return append([]byte(prefix+":"+version+":"), ad...)
+}
+
+var (
+	ErrInvalid    = errors.New("invalid sealed secret")
+	ErrUnknownKey = errors.New("unknown key-encryption key")
+	ErrOpen       = errors.New("sealed secret cannot be opened")
+)
+
+// KeyProvider wraps and unwraps data keys with key-encryption keys
+type KeyProvider interface {
+	// Wrap encrypt dek with the current key-encryption key, returning its identifier
+	Wrap(dek []byte) (keyID string, wrapped []byte, err error)
+	// Unwrap decrypt a data key wrapped by the key-encryption key keyID
+	Unwrap(keyID string, wrapped []byte) ([]byte, error)
+	// CurrentKeyID the identifier of the key-encryption key used by Wrap
+	CurrentKeyID() string
+}
+
+// Secret an encrypted OTP secret, store it with String and load it with Parse
+type Secret struct {
+	KeyID      string // The key-encryption key wrapping the data key
+	WrappedKey []byte // The wrapped data key
+	Nonce      []byte // The GCM nonce of Ciphertext
+	Ciphertext []byte // The secret encrypted under the data key
+}
+
+// Seal encrypt secret under a new data key wrapped by p. The associated data ad, e.g. the ID of
+// the record storing the secret, is authenticated but not stored: Open must be given the same
+// ad, so a secret copied into another record does not open there.
+func Seal(p KeyProvider, secret, ad []byte) (*Secret, error) {
+	return seal(p, secret, ad, rand.Reader)
+}
+
+func seal(p KeyProvider, secret, ad []byte, r io.Reader) (*Secret, error) {
+	dek := make([]byte, dekSize)
+	if _, err := io.ReadFull(r, dek); err != nil {
+		return nil, err
+	}
+	defer clear(dek)
+
+	nonce, ciphertext, err := gcmSeal(dek, secret, additionalData(ad), r)
+	if err != nil {
+		return nil, err
+	}
+	keyID, wrapped, err := p.Wrap(dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key failed: %w", err)
+	}
+
+	return &Secret{KeyID: keyID, WrappedKey: wrapped, Nonce: nonce, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypt the secret with the data key unwrapped by p, ad must be the data given to Seal
+func (s *Secret) Open(p KeyProvider, ad []byte) ([]byte, error) {
+	dek, err := p.Unwrap(s.KeyID, s.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpen, err)
+	}
+	defer clear(dek)
+
+	secret, err := gcmOpen(dek, s.Nonce, s.Ciphertext, additionalData(ad))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpen, err)
+	}
+
+	return secret, nil
+}
+
+// Rewrap wrap the data key with the current key-encryption key of p, after a rotation.
+// The ciphertext is unchanged; s is returned as is when it already uses the current key.
+func (s *Secret) Rewrap(p KeyProvider) (*Secret, error) {
+	if s.KeyID == p.CurrentKeyID() {
+		return s, nil
+	}
+	dek, err := p.Unwrap(s.KeyID, s.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrOpen, err)
+	}
+	defer clear(dek)
+
+	keyID, wrapped, err := p.Wrap(dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key failed: %w", err)
+	}
+
+	return &Secret{KeyID: keyID, WrappedKey: wrapped, Nonce: s.Nonce, Ciphertext: s.Ciphertext}, nil
+}
+
+// String the storage form, sealed:v1:<key id>:<wrapped key>:<nonce>:<ciphertext> in base64url
+func (s *Secret) String() string {
+	enc := base64.RawURLEncoding
+	return strings.Join([]string{
+		prefix, version,
+		enc.EncodeToString([]byte(s.KeyID)),
+		enc.EncodeToString(s.WrappedKey),
+		enc.EncodeToString(s.Nonce),
+		enc.EncodeToString(s.Ciphertext),
+	}, ":")
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (s *Secret) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *Secret) UnmarshalText(text []byte) error {
+	p, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*s = *p
+
+	return nil
+}
+
+// Parse the storage form of a secret
+func Parse(text string) (*Secret, error) {
+	parts := strings.Split(strings.TrimSpace(text), ":")
+	if len(parts) != 6 || parts[0] != prefix {
+		return nil, ErrInvalid
+	}
+	if parts[1] != version {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrInvalid, parts[1])
+	}
+
+	var fields [4][]byte
+	for i := range fields {
+		b, err := base64.RawURLEncoding.DecodeString(parts[i+2])
+		if err != nil || len(b) == 0 {
+			return nil, ErrInvalid
+		}
+		fields[i] = b
+	}
+
+	return &Secret{KeyID: string(fields[0]), WrappedKey: fields[1], Nonce: fields[2], Ciphertext: fields[3]}, nil
+}
+
+// gcmSeal encrypt plain with AES-GCM under a random nonce
+func gcmSeal(key, plain, ad []byte, r io.Reader) (nonce, ciphertext []byte, err error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, aead.NonceSize())
+	if _, err = io.ReadFull(r, nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return nonce, aead.Seal(nil, nonce, plain, ad), nil
+}
+
+// gcmOpen decrypt and authenticate an AES-GCM ciphertext
+func gcmOpen(key, nonce, ciphertext, ad []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+
+	return aead.Open(nil, nonce, ciphertext, ad)
+}
@@ -0,0 +1,257 @@
+package sealed
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func newProvider(t *testing.T, id string) *LocalProvider {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewLocalProvider(id, map[string][]byte{id: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSealOpen(t *testing.T) {
+	p := newProvider(t, "k1")
+	secret := []byte("12345678901234567890")
+
+	s, err := Seal(p, secret, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.KeyID != "k1" || bytes.Contains(s.Ciphertext, secret) {
+		t.Fatalf("sealed = %+v", s)
+	}
+	got, err := s.Open(p, nil)
+	if err != nil || !bytes.Equal(got, secret) {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	// each record has its own data key
+	s2, _ := Seal(p, secret, nil)
+	if bytes.Equal(s.WrappedKey, s2.WrappedKey) || bytes.Equal(s.Ciphertext, s2.Ciphertext) {
+		t.Fatal("data keys are reused")
+	}
+
+	tampered := *s
+	tampered.Ciphertext = bytes.Clone(s.Ciphertext)
+	tampered.Ciphertext[0] ^= 1
+	if _, err = tampered.Open(p, nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("tampered ciphertext = %v", err)
+	}
+	if _, err = s.Open(newProvider(t, "k1"), nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("other provider = %v", err)
+	}
+}
+
+func TestAssociatedData(t *testing.T) {
+	p := newProvider(t, "k1")
+	alice, err := Seal(p, []byte("alice secret"), []byte("user:alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := alice.Open(p, []byte("user:alice")); err != nil || string(got) != "alice secret" {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+	// a sealed secret copied into another record does not open there
+	for _, ad := range [][]byte{[]byte("user:mallory"), nil} {
+		if _, err = alice.Open(p, ad); !errors.Is(err, ErrOpen) {
+			t.Fatalf("Open(%q) = %v", ad, err)
+		}
+	}
+	rewrapped, err := alice.Rewrap(newProviderWith(t, p))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = rewrapped.Open(p, []byte("user:mallory")); !errors.Is(err, ErrOpen) {
+		t.Fatalf("rewrapped Open = %v", err)
+	}
+}
+
+// newProviderWith p rotated to a new current key
+func newProviderWith(t *testing.T, p *LocalProvider) *LocalProvider {
+	t.Helper()
+	key, _ := GenerateKey()
+	if err := p.Rotate("k-next", key); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestRetireConcurrent(t *testing.T) {
+	p := newProvider(t, "k1")
+	s, err := Seal(p, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, _ := GenerateKey()
+	if err = p.Rotate("k2", key); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				// either opens with the intact key or fails with ErrUnknownKey
+				if got, err := s.Open(p, nil); err != nil && !errors.Is(err, ErrUnknownKey) || err == nil && string(got) != "secret" {
+					t.Errorf("Open = %q, %v", got, err)
+					return
+				}
+				if _, err := Seal(p, []byte("secret"), nil); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	if err = p.Retire("k1"); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+}
+
+func TestParse(t *testing.T) {
+	p := newProvider(t, "k1")
+	s, err := Seal(p, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := s.String()
+	parsed, err := Parse(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := parsed.Open(p, nil); err != nil || string(got) != "secret" {
+		t.Fatalf("Open(Parse) = %q, %v", got, err)
+	}
+
+	data, err := json.Marshal(struct{ S *Secret }{s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var record struct{ S *Secret }
+	if err = json.Unmarshal(data, &record); err != nil || record.S.String() != text {
+		t.Fatalf("json round trip = %v, %v", record.S, err)
+	}
+
+	for _, bad := range []string{"", "JBSWY3DPEHPK3PXP", "sealed:v2:a:b:c:d", "sealed:v1:a:b:c", "sealed:v1:a:b:c:!"} {
+		if _, err = Parse(bad); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) = %v", bad, err)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	p := newProvider(t, "k1")
+	s, err := Seal(p, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, _ := GenerateKey()
+	if err = p.Rotate("k2", key); err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Rotate("k1", key); err == nil {
+		t.Fatal("existing key id accepted")
+	}
+	if p.CurrentKeyID() != "k2" {
+		t.Fatalf("current = %s", p.CurrentKeyID())
+	}
+	// secrets wrapped by the old key still open
+	if got, err := s.Open(p, nil); err != nil || string(got) != "secret" {
+		t.Fatalf("Open after rotation = %q, %v", got, err)
+	}
+
+	rewrapped, err := s.Rewrap(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rewrapped.KeyID != "k2" || !bytes.Equal(rewrapped.Ciphertext, s.Ciphertext) {
+		t.Fatalf("rewrapped = %+v", rewrapped)
+	}
+	if again, _ := rewrapped.Rewrap(p); again != rewrapped {
+		t.Fatal("rewrap with the current key changed the secret")
+	}
+
+	if err = p.Retire("k2"); err == nil {
+		t.Fatal("current key retired")
+	}
+	if err = p.Retire("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Open(p, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("Open with retired key = %v", err)
+	}
+	if got, err := rewrapped.Open(p, nil); err != nil || string(got) != "secret" {
+		t.Fatalf("Open rewrapped = %q, %v", got, err)
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	p := newProvider(t, "k1")
+	key, _ := GenerateKey()
+	if err := p.Rotate("k2", key); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := Seal(p, []byte("secret"), nil)
+
+	path := filepath.Join(t.TempDir(), "kek.json")
+	if err := p.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0o600 {
+		t.Fatalf("key file mode = %v, %v", fi, err)
+	}
+
+	loaded, err := LoadKeyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.CurrentKeyID() != "k2" || len(loaded.KeyIDs()) != 2 {
+		t.Fatalf("loaded keys = %s %v", loaded.CurrentKeyID(), loaded.KeyIDs())
+	}
+	if got, err := s.Open(loaded, nil); err != nil || string(got) != "secret" {
+		t.Fatalf("Open with loaded provider = %q, %v", got, err)
+	}
+}
+
+func TestPassphraseProvider(t *testing.T) {
+	salt := []byte("otp-salt")
+	p, err := NewPassphraseProvider("pass", "correct horse", salt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Seal(p, []byte("secret"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	same, _ := NewPassphraseProvider("pass", "correct horse", salt)
+	if got, err := s.Open(same, nil); err != nil || string(got) != "secret" {
+		t.Fatalf("Open with the same passphrase = %q, %v", got, err)
+	}
+	wrong, _ := NewPassphraseProvider("pass", "battery staple", salt)
+	if _, err = s.Open(wrong, nil); !errors.Is(err, ErrOpen) {
+		t.Fatalf("Open with a wrong passphrase = %v", err)
+	}
+	if _, err = NewPassphraseProvider("pass", "", salt); err == nil {
+		t.Fatal("empty passphrase accepted")
+	}
+}
@@ -0,0 +1,90 @@
+package throttle
+
+import (
+	"math"
+	"time"
+)
+
+// Policy decides how failed attempts lock a key (RFC 4226 §7.3)
+type Policy interface {
+	// UnlockAt the time a key becomes usable again after failures consecutive failures,
+	// the last one at last. A zero or past time means the key is not locked.
+	UnlockAt(failures int, last time.Time) time.Time
+	// Remaining the attempts left before lockout, -1 when unlimited
+	Remaining(failures int) int
+}
+
+// FixedLockout locks a key for Duration after MaxFailures consecutive failures.
+// Once the lockout expires the key gets MaxFailures new attempts.
+type FixedLockout struct {
+	MaxFailures int           // The failures allowed before lockout
+	Duration    time.Duration // How long the lockout lasts
+}
+
+func (f FixedLockout) UnlockAt(failures int, last time.Time) time.Time {
+	if f.MaxFailures <= 0 || failures < f.MaxFailures {
+		return time.Time{}
+	}
+	return last.Add(f.Duration)
+}
+
+func (f FixedLockout) Remaining(failures int) int {
+	if f.MaxFailures <= 0 {
+		return -1
+	}
+	return max(f.MaxFailures-failures, 0)
+}
+
+// Backoff delays the next attempt exponentially: after Free failures each further failure
+// doubles the delay, starting at BaseDelay and capped at MaxDelay.
+type Backoff struct {
+	Free      int           // The failures allowed without delay
+	BaseDelay time.Duration // The delay after the first counted failure
+	MaxDelay  time.Duration // The upper bound of the delay. 0 means no bound
+}
+
+func (b Backoff) UnlockAt(failures int, last time.Time) time.Time {
+	n := failures - b.Free
+	if n <= 0 || b.BaseDelay <= 0 {
+		return time.Time{}
+	}
+	delay := b.BaseDelay
+	for i := 1; i < n && (b.MaxDelay <= 0 || delay < b.MaxDelay) && delay <= math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if b.MaxDelay > 0 && delay > b.MaxDelay {
+		delay = b.MaxDelay
+	}
+	return last.Add(delay)
+}
+
+func (b Backoff) Remaining(int) int {
+	return -1
+}
+
+// Combine apply several policies at once: the latest unlock time and the fewest remaining attempts win
+func Combine(ps ...Policy) Policy {
+	return combined(ps)
+}
+
+type combined []Policy
+
+func (c combined) UnlockAt(failures int, last time.Time) time.Time {
+	var at time.Time
+	for _, p := range c {
+		if t := p.UnlockAt(failures, last); t.After(at) {
+			at = t
+		}
+	}
+	return at
+}
+
+func (c combined) Remaining(failures int) int {
+	remaining := -1
+	for _, p := range c {
+		if r := p.Remaining(failures); r >= 0 && (remaining < 0 || r < remaining) {
+			remaining = r
+		}
+	}
+	return remaining
+}
@@ -0,0 +1,351 @@
+package throttle
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/common"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrLocked too many failed attempts, see LockedError for the unlock time
+var ErrLocked = errors.New("too many failed attempts")
+
+// Status the throttling state of a set of keys
+type Status struct {
+	Locked    bool      // Whether validation is currently refused
+	Remaining int       // The attempts left before lockout, -1 when unlimited
+	UnlockAt  time.Time // When the lock is released, zero when not locked
+}
+
+// LockedError returned while a key is locked
+type LockedError struct {
+	Status Status
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrLocked.Error(), e.Status.UnlockAt.Format(time.RFC3339))
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// AccountKey the throttling key of an account
+func AccountKey(issuer, accountName string) string {
+	return "account:" + issuer + ":" + accountName
+}
+
+// SourceKey the throttling key of a request source, e.g. a client IP
+func SourceKey(source string) string {
+	return sourcePrefix + source
+}
+
+const sourcePrefix = "source:"
+
+// DefaultResetAfter how long an unlocked key keeps its failures without a new attempt
+const DefaultResetAfter = time.Hour
+
+type state struct {
+	failures int       // consecutive failures
+	pending  int       // attempts reserved by Attempt and not yet reported
+	last     time.Time // the last failure or reservation
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Limiter counts consecutive failures per key and applies a Policy. It is safe for concurrent use.
+type Limiter struct {
+	Policy     Policy
+	Clock      abstract.Clock // Defaults to the system clock
+	ResetAfter time.Duration  // Unlocked keys idle this long are forgotten. Default is DefaultResetAfter
+
+	mu     sync.Mutex
+	states map[string]*state
+	swept  time.Time
+}
+
+// NewLimiter returns a Limiter applying p
+func NewLimiter(p Policy) (*Limiter, error) {
+	if p == nil {
+		return nil, fmt.Errorf("%w: throttle policy is nil", common.ErrInvalidOption)
+	}
+
+	return &Limiter{Policy: p, Clock: systemClock{}, states: map[string]*state{}}, nil
+}
+
+func (l *Limiter) now() time.Time {
+	if l.Clock == nil {
+		return time.Now()
+	}
+	return l.Clock.Now()
+}
+
+func (l *Limiter) resetAfter() time.Duration {
+	if l.ResetAfter <= 0 {
+		return DefaultResetAfter
+	}
+	return l.ResetAfter
+}
+
+// Status report the combined state of keys, the most restrictive key wins
+func (l *Limiter) Status(keys ...string) Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.status(l.now(), keys)
+}
+
+// Attempt check keys and, unless they are locked, reserve an attempt on each of them in the
+// same step. A reserved attempt counts as a failure until it is reported with Success or
+// Failure, so concurrent attempts cannot exceed the policy. It reports whether the attempt
+// may proceed.
+func (l *Limiter) Attempt(keys ...string) (Status, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if st := l.status(now, keys); st.Locked {
+		return st, false
+	}
+	for _, k := range keys {
+		s := l.state(k)
+		s.pending++
+		s.last = now
+	}
+
+	return l.status(now, keys), true
+}
+
+// Failure record a failed attempt for every key and return the new status. It commits an
+// attempt reserved by Attempt.
+func (l *Limiter) Failure(keys ...string) Status {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+	for _, k := range keys {
+		s := l.state(k)
+		if s.pending > 0 {
+			s.pending--
+		}
+		s.failures++
+		s.last = now
+	}
+
+	return l.status(now, keys)
+}
+
+// Success forget the failures of keys, releasing an attempt reserved by Attempt
+func (l *Limiter) Success(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, k := range keys {
+		s, ok := l.states[k]
+		if !ok {
+			continue
+		}
+		if s.pending > 0 {
+			s.pending--
+		}
+		s.failures = 0
+		if s.pending == 0 {
+			delete(l.states, k)
+		}
+	}
+}
+
+// Release return attempts reserved by Attempt without recording a result, the failures
+// of keys are kept until they expire
+func (l *Limiter) Release(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, k := range keys {
+		s, ok := l.states[k]
+		if !ok {
+			continue
+		}
+		if s.pending > 0 {
+			s.pending--
+		}
+		if s.pending == 0 && s.failures == 0 {
+			delete(l.states, k)
+		}
+	}
+}
+
+// Len the number of keys with recorded failures or reserved attempts
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return len(l.states)
+}
+
+// state the state of key k, created when missing
+func (l *Limiter) state(k string) *state {
+	if l.states == nil {
+		l.states = map[string]*state{}
+	}
+	s, ok := l.states[k]
+	if !ok {
+		s = &state{}
+		l.states[k] = s
+	}
+	return s
+}
+
+// sweep drop the idle states, at most once per ResetAfter
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < l.resetAfter() {
+		return
+	}
+	l.swept = now
+	for k, s := range l.states {
+		if l.idle(s, now) {
+			delete(l.states, k)
+		}
+	}
+}
+
+// idle reports whether s is unlocked, has no reserved attempt and was not used for ResetAfter
+func (l *Limiter) idle(s *state, now time.Time) bool {
+	return s.pending == 0 && !now.Before(l.Policy.UnlockAt(s.failures, s.last)) && now.Sub(s.last) >= l.resetAfter()
+}
+
+func (l *Limiter) status(now time.Time, keys []string) Status {
+	st := Status{Remaining: -1}
+	for _, k := range keys {
+		s, ok := l.states[k]
+		if ok && l.idle(s, now) {
+			delete(l.states, k)
+			ok = false
+		}
+		if !ok {
+			if r := l.Policy.Remaining(0); r >= 0 && (st.Remaining < 0 || r < st.Remaining) {
+				st.Remaining = r
+			}
+			continue
+		}
+		// reserved attempts count as failures made now
+		failures, last := s.failures+s.pending, s.last
+		unlockAt := l.Policy.UnlockAt(failures, last)
+		remaining := l.Policy.Remaining(failures)
+		if remaining == 0 && s.pending == 0 && !now.Before(unlockAt) {
+			// the lockout has expired, start over
+			delete(l.states, k)
+			remaining = l.Policy.Remaining(0)
+		} else if now.Before(unlockAt) {
+			st.Locked = true
+			if unlockAt.After(st.UnlockAt) {
+				st.UnlockAt = unlockAt
+			}
+		} else if remaining == 0 {
+			// the remaining attempts are all in flight
+			st.Locked = true
+			if now.After(st.UnlockAt) {
+				st.UnlockAt = now
+			}
+		}
+		if remaining >= 0 && (st.Remaining < 0 || remaining < st.Remaining) {
+			st.Remaining = remaining
+		}
+	}
+
+	return st
+}
+
+// Guard an Otp whose Validate and Verify are throttled by a Limiter
+type Guard struct {
+	abstract.Otp
+	Limiter *Limiter
+	Keys    []string // The throttling keys of this attempt, e.g. AccountKey and SourceKey
+}
+
+// success reset the keys of an accepted code. A SourceKey keeps its failures until they
+// expire through ResetAfter, so a source guessing many accounts is not cleared by one of them.
+func (g *Guard) success() {
+	var reset, release []string
+	for _, k := range g.Keys {
+		if strings.HasPrefix(k, sourcePrefix) {
+			release = append(release, k)
+		} else {
+			reset = append(reset, k)
+		}
+	}
+	g.Limiter.Success(reset...)
+	g.Limiter.Release(release...)
+}
+
+// Wrap throttle o with limiter under keys
+func Wrap(o abstract.Otp, limiter *Limiter, keys ...string) *Guard {
+	return &Guard{Otp: o, Limiter: limiter, Keys: keys}
+}
+
+// Validate verify dynamic password, returning a *LockedError while the keys are locked
+func (g *Guard) Validate(passCode string, counters ...any) (bool, error) {
+	ok, _, err := g.ValidateStatus(passCode, counters...)
+
+	return ok, err
+}
+
+// ValidateWith verify dynamic password, returning a *LockedError while the keys are locked
+func (g *Guard) ValidateWith(passCode string, req abstract.Request) (bool, error) {
+	ok, _, err := g.validate(func() (bool, error) { return g.Otp.ValidateWith(passCode, req) })
+
+	return ok, err
+}
+
+// ValidateStatus verify dynamic password and report the remaining attempts and unlock time
+func (g *Guard) ValidateStatus(passCode string, counters ...any) (bool, Status, error) {
+	return g.validate(func() (bool, error) { return g.Otp.Validate(passCode, counters...) })
+}
+
+// Verify verify dynamic password, failing with enum.ReasonLocked while the keys are locked
+func (g *Guard) Verify(passCode string, counters ...any) abstract.VerifyResult {
+	return g.verify(func() abstract.VerifyResult { return g.Otp.Verify(passCode, counters...) })
+}
+
+// VerifyWith verify dynamic password, failing with enum.ReasonLocked while the keys are locked
+func (g *Guard) VerifyWith(passCode string, req abstract.Request) abstract.VerifyResult {
+	return g.verify(func() abstract.VerifyResult { return g.Otp.VerifyWith(passCode, req) })
+}
+
+func (g *Guard) validate(fn func() (bool, error)) (bool, Status, error) {
+	if st, ok := g.Limiter.Attempt(g.Keys...); !ok {
+		return false, st, &LockedError{Status: st}
+	}
+
+	ok, err := fn()
+	if ok {
+		g.success()
+		return true, g.Limiter.Status(g.Keys...), nil
+	}
+
+	return false, g.Limiter.Failure(g.Keys...), err
+}
+
+func (g *Guard) verify(fn func() abstract.VerifyResult) abstract.VerifyResult {
+	if st, ok := g.Limiter.Attempt(g.Keys...); !ok {
+		return abstract.VerifyResult{Reason: enum.ReasonLocked, Err: &LockedError{Status: st}}
+	}
+
+	res := fn()
+	if res.Valid {
+		g.success()
+	} else {
+		g.Limiter.Failure(g.Keys...)
+	}
+
+	return res
+}
@@ -0,0 +1,141 @@
+package throttle
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/totp"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGuard(t *testing.T) {
+	clock := totp.NewFakeClock(time.Unix(1111111109, 0))
+	limiter, err := NewLimiter(Combine(
+		FixedLockout{MaxFailures: 3, Duration: time.Minute},
+		Backoff{Free: 1, BaseDelay: time.Second, MaxDelay: 4 * time.Second},
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.Clock = clock
+	obj, err := totp.New(totp.WithSecret([]byte("12345678901234567890")), totp.WithDigits(enum.DigitEight), totp.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	guard := Wrap(obj, limiter, AccountKey("ACME", "alice"), SourceKey("10.0.0.1"))
+
+	if _, st, _ := guard.ValidateStatus("00000000"); st.Locked || st.Remaining != 2 {
+		t.Fatalf("unexpected status %+v", st)
+	}
+	_, st, _ := guard.ValidateStatus("00000000")
+	if !st.Locked || !st.UnlockAt.Equal(clock.Now().Add(time.Second)) {
+		t.Fatalf("expected back-off, got %+v", st)
+	}
+	if _, err := guard.Validate("07081804"); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked, got %v", err)
+	}
+
+	clock.Advance(time.Second)
+	if _, st, _ = guard.ValidateStatus("00000000"); !st.Locked || st.Remaining != 0 || !st.UnlockAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("expected lockout, got %+v", st)
+	}
+	if res := guard.Verify("07081804"); res.Reason != enum.ReasonLocked {
+		t.Fatalf("expected locked, got %+v", res)
+	}
+
+	clock.Advance(time.Minute)
+	if ok, err := guard.Validate("07081804"); ok {
+		t.Fatalf("expected the step to have passed, got %v", err)
+	}
+	clock.Set(time.Unix(1111111109, 0))
+	if ok, err := guard.Validate("07081804"); !ok || err != nil {
+		t.Fatalf("expected success after the lockout expired, got %v", err)
+	}
+	if st = limiter.Status(guard.Keys[0]); st.Locked || st.Remaining != 3 {
+		t.Fatalf("expected reset account status, got %+v", st)
+	}
+	// the source keeps its failures until they expire
+	if st = limiter.Status(guard.Keys[1]); st.Remaining == 3 {
+		t.Fatalf("expected the source failures to be kept, got %+v", st)
+	}
+	clock.Advance(DefaultResetAfter + time.Second)
+	if st = limiter.Status(guard.Keys...); st.Locked || st.Remaining != 3 {
+		t.Fatalf("expected reset status, got %+v", st)
+	}
+}
+
+// slowOtp an Otp rejecting every code after a delay, counting the attempts it evaluated
+type slowOtp struct {
+	abstract.Otp
+	calls atomic.Int64
+}
+
+func (s *slowOtp) Validate(string, ...any) (bool, error) {
+	s.calls.Add(1)
+	time.Sleep(20 * time.Millisecond)
+	return false, nil
+}
+
+func TestGuardConcurrent(t *testing.T) {
+	if _, err := NewLimiter(nil); !errors.Is(err, common.ErrInvalidOption) {
+		t.Fatalf("nil policy = %v", err)
+	}
+	limiter, err := NewLimiter(FixedLockout{MaxFailures: 3, Duration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &slowOtp{}
+	guard := Wrap(obj, limiter, AccountKey("ACME", "alice"))
+
+	var wg sync.WaitGroup
+	var locked atomic.Int64
+	for range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := guard.Validate("000000"); errors.Is(err, ErrLocked) {
+				locked.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if obj.calls.Load() != 3 || locked.Load() != 197 {
+		t.Fatalf("evaluated %d guesses, locked %d; want 3 and 197", obj.calls.Load(), locked.Load())
+	}
+	if st := limiter.Status(guard.Keys...); !st.Locked || st.Remaining != 0 {
+		t.Fatalf("status = %+v", st)
+	}
+}
+
+func TestLimiterEviction(t *testing.T) {
+	clock := totp.NewFakeClock(time.Unix(1000, 0))
+	limiter, err := NewLimiter(Backoff{BaseDelay: time.Second, MaxDelay: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter.Clock, limiter.ResetAfter = clock, 10*time.Minute
+
+	for i := range 5 {
+		limiter.Failure(SourceKey(fmt.Sprint("10.0.0.", i)))
+	}
+	clock.Advance(5 * time.Minute)
+	limiter.Failure(SourceKey("10.0.1.1"))
+	if st := limiter.Status(SourceKey("10.0.0.1")); st.Locked {
+		t.Fatalf("status after the back-off = %+v", st)
+	}
+
+	// the idle sources are forgotten, the recent one keeps its back-off
+	clock.Advance(6 * time.Minute)
+	limiter.Failure(SourceKey("10.0.1.1"))
+	if st := limiter.Status(SourceKey("10.0.1.1")); !st.Locked || !st.UnlockAt.Equal(clock.Now().Add(2*time.Second)) {
+		t.Fatalf("recent source = %+v", st)
+	}
+	if n := limiter.Len(); n != 1 {
+		t.Fatalf("%d tracked keys; want 1", n)
+	}
+}
@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/util"
+	"time"
+)
+
+// CodeInfo a dynamic password with the time step it is valid for
+type CodeInfo struct {
+	Code      string        // The dynamic password
+	Counter   uint64        // The time step counter
+	Start     time.Time     // The start of the time step
+	End       time.Time     // The end of the time step, when the next code takes over
+	Remaining time.Duration // The validity left at the time the code was generated
+}
+
+// GenerateCodeInfo generate dynamic password with its validity, see GenerateCode
+func (t *TOtp) GenerateCodeInfo(counters ...any) (CodeInfo, error) {
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return CodeInfo{}, err
+	}
+
+	return t.GenerateCodeInfoWith(req)
+}
+
+// GenerateCodeInfoWith generate the dynamic password for the time step containing req.Time with its validity
+func (t *TOtp) GenerateCodeInfoWith(req abstract.Request) (CodeInfo, error) {
+	h, args, err := t.generator(req)
+	if err != nil {
+		return CodeInfo{}, err
+	}
+
+	return t.codeInfo(h, t.timeOf(req), args)
+}
+
+// StepStart the start of the time step containing tm
+func (t *TOtp) StepStart(tm time.Time) time.Time {
+	_, start, _ := t.step(tm)
+	return start
+}
+
+// NextRotation the time the code valid at tm is replaced, the end of its time step
+func (t *TOtp) NextRotation(tm time.Time) time.Time {
+	_, _, end := t.step(tm)
+	return end
+}
+
+// Remaining the validity left at tm of the current code
+func (t *TOtp) Remaining(tm time.Time) time.Duration {
+	return t.NextRotation(tm).Sub(tm)
+}
+
+// step the counter, start and end of the time step containing tm, in the location of tm
+func (t *TOtp) step(tm time.Time) (int64, time.Time, time.Time) {
+	counter := t.counterAt(tm)
+	start := time.Unix(counter*int64(t.Period), 0).In(tm.Location())
+
+	return counter, start, start.Add(time.Duration(t.Period) * time.Second)
+}
@@ -0,0 +1,201 @@
+package totp
+
+import (
+	"fmt"
+	"github.com/dhlanshan/otp/drift"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/sealed"
+	"io"
+)
+
+// Option configures a TOtp built by New
+type Option func(t *TOtp) error
+
+// New build and initialize a TOtp, unset fields take the same defaults as NewTOtp
+func New(opts ...Option) (*TOtp, error) {
+	t := &TOtp{}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, &common.InitError{OtpType: "TOTP", Err: err}
+		}
+	}
+	if err := t.Init(); err != nil {
+		return nil, &common.InitError{OtpType: "TOTP", Err: err}
+	}
+
+	return t, nil
+}
+
+// WithIssuer set the name of the issuer/company
+func WithIssuer(issuer string) Option {
+	return func(t *TOtp) error {
+		t.Issuer = issuer
+		return nil
+	}
+}
+
+// WithAccountName set the user's account name
+func WithAccountName(accountName string) Option {
+	return func(t *TOtp) error {
+		t.AccountName = accountName
+		return nil
+	}
+}
+
+// WithSecret use the raw secret key
+func WithSecret(secret []byte) Option {
+	return func(t *TOtp) error {
+		if len(secret) == 0 {
+			return fmt.Errorf("%w: secret is empty", common.ErrInvalidOption)
+		}
+		t.Secret, t.EncSecret = secret, ""
+		return nil
+	}
+}
+
+// WithEncSecret use the base32 encoded secret key
+func WithEncSecret(encSecret string) Option {
+	return func(t *TOtp) error {
+		secret, err := util.DecodeBase32Secret(encSecret)
+		if err != nil || len(secret) == 0 {
+			return fmt.Errorf("%w: %q", common.ErrSecretDecode, encSecret)
+		}
+		t.Secret, t.EncSecret = secret, encSecret
+		return nil
+	}
+}
+
+// WithSealedSecret use the secret key sealed by s, opened with the key-encryption keys of p and
+// the associated data ad given to sealed.Seal
+func WithSealedSecret(s *sealed.Secret, p sealed.KeyProvider, ad []byte) Option {
+	return func(t *TOtp) error {
+		if s == nil || p == nil {
+			return fmt.Errorf("%w: sealed secret and key provider are required", common.ErrInvalidOption)
+		}
+		secret, err := s.Open(p, ad)
+		if err != nil {
+			return fmt.Errorf("%w: %w", common.ErrSecretDecode, err)
+		}
+		if len(secret) == 0 {
+			return fmt.Errorf("%w: sealed secret is empty", common.ErrSecretDecode)
+		}
+		t.Secret, t.EncSecret = secret, ""
+		return nil
+	}
+}
+
+// WithRandomSecret generate a secret key of size bytes from r, crypto/rand when r is nil
+func WithRandomSecret(size uint, r io.Reader) Option {
+	return func(t *TOtp) error {
+		if size == 0 {
+			return fmt.Errorf("%w: secret size is zero", common.ErrInvalidOption)
+		}
+		t.SecretSize, t.Secret, t.EncSecret = size, nil, ""
+		if r != nil {
+			t.Rand = r
+		}
+		return nil
+	}
+}
+
+// WithDigits set the number of digits, between enum.MinDigits and enum.MaxDigits
+func WithDigits(digits enum.DigitEnum) Option {
+	return func(t *TOtp) error {
+		if !digits.Valid() {
+			return fmt.Errorf("%w: %d, must be between %d and %d", common.ErrInvalidDigits, digits, enum.MinDigits, enum.MaxDigits)
+		}
+		t.Digits = digits
+		return nil
+	}
+}
+
+// WithAlgorithm set the HMAC algorithm
+func WithAlgorithm(algorithm enum.AlgorithmEnum) Option {
+	return func(t *TOtp) error {
+		if !algorithm.Valid() {
+			return fmt.Errorf("%w: %d", common.ErrInvalidAlgorithm, algorithm)
+		}
+		t.Algorithm = algorithm
+		return nil
+	}
+}
+
+// WithPattern set the generation pattern, it must be registered when New initializes the TOtp
+func WithPattern(name enum.PatternEnum) Option {
+	return func(t *TOtp) error {
+		if name == "" {
+			return fmt.Errorf("%w: pattern is empty", common.ErrInvalidOption)
+		}
+		t.Pattern = name
+		return nil
+	}
+}
+
+// WithRegistry look patterns up in r instead of pattern.Default()
+func WithRegistry(r *pattern.Registry) Option {
+	return func(t *TOtp) error {
+		if r == nil {
+			return fmt.Errorf("%w: registry is nil", common.ErrInvalidOption)
+		}
+		t.Registry = r
+		return nil
+	}
+}
+
+// WithHost set the host of the key uri
+func WithHost(host string) Option {
+	return func(t *TOtp) error {
+		t.Host = host
+		return nil
+	}
+}
+
+// WithPeriod set the seconds a code is valid
+func WithPeriod(period uint) Option {
+	return func(t *TOtp) error {
+		if period == 0 {
+			return fmt.Errorf("%w: period is zero", common.ErrInvalidPeriod)
+		}
+		t.Period = period
+		return nil
+	}
+}
+
+// WithSkew accept codes up to skew periods before or after the current one
+func WithSkew(skew uint) Option {
+	return func(t *TOtp) error {
+		t.Skew = skew
+		return nil
+	}
+}
+
+// WithClock use c as the time source
+func WithClock(c Clock) Option {
+	return func(t *TOtp) error {
+		if c == nil {
+			return fmt.Errorf("%w: clock is nil", common.ErrInvalidOption)
+		}
+		t.Clock = c
+		return nil
+	}
+}
+
+// WithReplayStore reject codes whose time step was already accepted
+func WithReplayStore(s replay.Store) Option {
+	return func(t *TOtp) error {
+		t.ReplayStore = s
+		return nil
+	}
+}
+
+// WithDriftStore remember the clock drift per account, up to maxDrift steps (0 for the default)
+func WithDriftStore(s drift.Store, maxDrift uint) Option {
+	return func(t *TOtp) error {
+		t.DriftStore, t.MaxDrift = s, maxDrift
+		return nil
+	}
+}
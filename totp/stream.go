@@ -0,0 +1,154 @@
+package totp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/hotp"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/common"
+	"iter"
+	"time"
+)
+
+// MaxRangeSteps the most time steps GenerateRange returns, iterate Range for longer spans
+const MaxRangeSteps = 10000
+
+// GenerateRange generate the dynamic passwords of every time step overlapping [from, to],
+// at most MaxRangeSteps of them
+func (t *TOtp) GenerateRange(from, to time.Time, req abstract.Request) ([]CodeInfo, error) {
+	seq, err := t.Range(from, to, req)
+	if err != nil {
+		return nil, err
+	}
+	steps := t.counterAt(to) - t.counterAt(from) + 1
+	if steps > MaxRangeSteps {
+		return nil, fmt.Errorf("%w: range of %d steps exceeds %d", common.ErrInvalidParameter, steps, MaxRangeSteps)
+	}
+
+	infos := make([]CodeInfo, 0, steps)
+	for info, err := range seq {
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// Range iterate the dynamic passwords of every time step overlapping [from, to], in order.
+// Remaining is measured from the later of from and the start of the step. When a code cannot
+// be generated the error is yielded with a zero CodeInfo and the iteration ends.
+func (t *TOtp) Range(from, to time.Time, req abstract.Request) (iter.Seq2[CodeInfo, error], error) {
+	if to.Before(from) {
+		return nil, errors.New("invalid range, to is before from")
+	}
+	h, args, err := t.generator(req)
+	if err != nil {
+		return nil, err
+	}
+	first, err := t.codeInfo(h, from, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(CodeInfo, error) bool) {
+		info := first
+		for {
+			if !yield(info, nil) || !info.End.Before(to) && !info.End.Equal(to) {
+				return
+			}
+			next, err := t.codeInfo(h, info.End, args)
+			if err != nil {
+				yield(CodeInfo{}, err)
+				return
+			}
+			info = next
+		}
+	}, nil
+}
+
+// Upcoming iterate the current dynamic password and then a new one at each step boundary of
+// Clock, until ctx is done or the loop breaks. Steps missed by a clock jump are skipped, and
+// the iteration ends if a later code cannot be generated.
+func (t *TOtp) Upcoming(ctx context.Context, req abstract.Request) (iter.Seq[CodeInfo], error) {
+	h, args, err := t.generator(req)
+	if err != nil {
+		return nil, err
+	}
+	first, err := t.codeInfo(h, t.Clock.Now(), args)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(CodeInfo) bool) {
+		info := first
+		for {
+			if ctx.Err() != nil || !yield(info) {
+				return
+			}
+			for {
+				now := t.Clock.Now()
+				if !now.Before(info.End) {
+					next, err := t.codeInfo(h, now, args)
+					if err != nil {
+						return
+					}
+					info = next
+					break
+				}
+				timer := time.NewTimer(info.End.Sub(now))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				case <-timer.C:
+				}
+			}
+		}
+	}, nil
+}
+
+// Stream send the codes of Upcoming on the returned channel, which is closed once ctx is done
+func (t *TOtp) Stream(ctx context.Context, req abstract.Request) (<-chan CodeInfo, error) {
+	seq, err := t.Upcoming(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan CodeInfo)
+	go func() {
+		defer close(ch)
+		for info := range seq {
+			select {
+			case ch <- info:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+// generator the HOtp computing the codes of t and the pattern arguments of req
+func (t *TOtp) generator(req abstract.Request) (*hotp.HOtp, []string, error) {
+	if t.Pattern == enum.Mobile && req.PIN == "" {
+		return nil, nil, common.ErrMissingPIN
+	}
+	h := &hotp.HOtp{Digits: t.Digits, Algorithm: t.Algorithm, Secret: t.Secret, Pattern: t.Pattern, Rand: t.Rand, Registry: t.Registry}
+
+	return h, req.Args(), nil
+}
+
+// codeInfo the dynamic password of the time step containing tm
+func (t *TOtp) codeInfo(h *hotp.HOtp, tm time.Time, args []string) (CodeInfo, error) {
+	counter, start, end := t.step(tm)
+	code, err := h.GenerateCodeForCounter(uint64(counter), args...)
+	if err != nil {
+		return CodeInfo{}, err
+	}
+
+	return CodeInfo{Code: code, Counter: uint64(counter), Start: start, End: end, Remaining: end.Sub(tm)}, nil
+}
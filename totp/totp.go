@@ -4,16 +4,23 @@ import (
 	"crypto/rand"
 	"errors"
 	"fmt"
+	"github.com/dhlanshan/otp/drift"
 	"github.com/dhlanshan/otp/enum"
 	"github.com/dhlanshan/otp/hotp"
+	"github.com/dhlanshan/otp/internal/abstract"
 	"github.com/dhlanshan/otp/internal/command"
 	"github.com/dhlanshan/otp/internal/common"
 	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/sealed"
 	"io"
 	"math"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type TOtp struct {
@@ -29,6 +36,11 @@ type TOtp struct {
 	Pattern     enum.PatternEnum   // The OTP generation pattern
 	Rand        io.Reader          //
 	Host        string             // The host of the key
+	Clock       Clock              // The time source. Defaults to the system clock
+	ReplayStore replay.Store       // Records accepted time steps per account to reject reused codes. Disabled when nil
+	DriftStore  drift.Store        // Records the observed clock drift per account and centers the window on it. Disabled when nil
+	MaxDrift    uint               // The largest drift in steps remembered by DriftStore. Default is 5
+	Registry    *pattern.Registry  // The patterns available to this instance. Defaults to pattern.Default()
 }
 
 // NewTOtp initializes and returns a new TOtp instance based on the provided CreateOtpCmd configuration.
@@ -46,16 +58,29 @@ func NewTOtp(cmd *command.CreateOtpCmd) (*TOtp, error) {
 		Pattern:     cmd.Pattern,
 		Rand:        rand.Reader,
 		Host:        cmd.Host,
+		Clock:       cmd.Clock,
+		ReplayStore: cmd.ReplayStore,
+		DriftStore:  cmd.DriftStore,
+		MaxDrift:    cmd.MaxDrift,
+		Registry:    cmd.Registry,
+	}
+	if cmd.SealedSecret != nil {
+		if err := WithSealedSecret(cmd.SealedSecret, cmd.KeyProvider, cmd.AssociatedData)(tObj); err != nil {
+			return nil, &common.InitError{OtpType: "TOTP", Err: err}
+		}
 	}
 	if err := tObj.Init(); err != nil {
-		return nil, errors.New(fmt.Sprintf("TOTP init failed: %s", err.Error()))
+		return nil, &common.InitError{OtpType: "TOTP", Err: err}
 	}
-	// Load default pattern
-	common.SetDefaultPattern()
-
 	return tObj, nil
 }
 
+// SealSecret encrypt the secret key with a data key wrapped by p, for storage in place of EncSecret.
+// ad binds the sealed secret to its record, see sealed.Seal.
+func (t *TOtp) SealSecret(p sealed.KeyProvider, ad []byte) (*sealed.Secret, error) {
+	return sealed.Seal(p, t.Secret, ad)
+}
+
 func (t *TOtp) Init() error {
 	if t.Issuer == "" {
 		t.Issuer = common.DefaultIssuer
@@ -72,13 +97,28 @@ func (t *TOtp) Init() error {
 	if t.Digits == 0 {
 		t.Digits = enum.DigitSix
 	}
+	if !t.Digits.Valid() {
+		return fmt.Errorf("%w: %d, must be between %d and %d", common.ErrInvalidDigits, t.Digits, enum.MinDigits, enum.MaxDigits)
+	}
 	if t.Rand == nil {
 		t.Rand = rand.Reader
 	}
+	if t.Clock == nil {
+		t.Clock = SystemClock
+	}
+	if !t.Algorithm.Valid() {
+		return fmt.Errorf("%w: %d", common.ErrInvalidAlgorithm, t.Algorithm)
+	}
+	if t.Registry == nil {
+		t.Registry = pattern.Default()
+	}
+	if t.MaxDrift == 0 {
+		t.MaxDrift = common.DefaultMaxDrift
+	}
 	if t.EncSecret != "" {
 		secret, err := util.DecodeBase32Secret(t.EncSecret)
 		if err != nil {
-			return errors.New("EncSecret key decoding failed")
+			return fmt.Errorf("%w: %w", common.ErrSecretDecode, err)
 		}
 		t.Secret = secret
 		t.SecretSize = uint(len(secret))
@@ -86,7 +126,7 @@ func (t *TOtp) Init() error {
 	if len(t.Secret) == 0 {
 		t.Secret = make([]byte, t.SecretSize)
 		if _, err := t.Rand.Read(t.Secret); err != nil {
-			return errors.New("init Secret failed")
+			return fmt.Errorf("%w: %w", common.ErrSecretGenerate, err)
 		}
 	} else {
 		t.SecretSize = uint(len(t.Secret))
@@ -103,29 +143,54 @@ func (t *TOtp) Init() error {
 	if t.Pattern == "" {
 		t.Pattern = enum.Standard
 	}
+	if _, ok := t.Registry.Lookup(t.Pattern); !ok {
+		return fmt.Errorf("%w: %s", common.ErrUnknownPattern, t.Pattern)
+	}
 	if t.Pattern == enum.Standard {
 		t.Host = "totp"
 	}
+	if t.Host == "" {
+		t.Host = string(t.Pattern)
+	}
 
 	return nil
 }
 
 // GenerateCode generate dynamic password
 func (t *TOtp) GenerateCode(counters ...any) ([]string, error) {
-	nowTime := time.Now().UTC()
-	counter := int64(math.Floor(float64(nowTime.Unix()) / float64(t.Period)))
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return nil, err
+	}
 
-	_, pin := util.ParameterParsing(t.Pattern, counters)
-	if t.Pattern == enum.Mobile && pin == "" {
-		return nil, errors.New("missing pin parameter")
+	return t.GenerateCodeWith(req)
+}
+
+// GenerateCodeAt generate the dynamic password for the time step containing tm
+func (t *TOtp) GenerateCodeAt(tm time.Time, counters ...any) ([]string, error) {
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return nil, err
+	}
+	req.Time = tm
+
+	return t.GenerateCodeWith(req)
+}
+
+// GenerateCodeWith generate the dynamic password for the time step containing req.Time
+func (t *TOtp) GenerateCodeWith(req abstract.Request) ([]string, error) {
+	counter := t.counterAt(t.timeOf(req))
+
+	if t.Pattern == enum.Mobile && req.PIN == "" {
+		return nil, common.ErrMissingPIN
 	}
 
 	newCounters := util.CalculateCounters(counter, 0)
-	hOpt := hotp.HOtp{Digits: t.Digits, Algorithm: t.Algorithm, Secret: t.Secret, Pattern: t.Pattern, Rand: t.Rand}
+	hOpt := hotp.HOtp{Digits: t.Digits, Algorithm: t.Algorithm, Secret: t.Secret, Pattern: t.Pattern, Rand: t.Rand, Registry: t.Registry}
 
 	passCodes := make([]string, 0, len(newCounters))
 	for _, c := range newCounters {
-		passCode, err := hOpt.GenerateCodeForCounter(c, pin)
+		passCode, err := hOpt.GenerateCodeForCounter(c, req.Args()...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to generate dynamic code: %w", err)
 		}
@@ -136,33 +201,125 @@ func (t *TOtp) GenerateCode(counters ...any) ([]string, error) {
 
 // Validate verify dynamic password
 func (t *TOtp) Validate(passCode string, counters ...any) (bool, error) {
-	nowTime := time.Now().UTC()
-	counter := int64(math.Floor(float64(nowTime.Unix()) / float64(t.Period)))
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return false, err
+	}
 
-	_, pin := util.ParameterParsing(t.Pattern, counters)
-	if t.Pattern == enum.Mobile && pin == "" {
-		return false, errors.New("missing pin parameter")
+	return t.ValidateWith(passCode, req)
+}
+
+// ValidateAt verify dynamic password against the time step containing tm
+func (t *TOtp) ValidateAt(passCode string, tm time.Time, counters ...any) (bool, error) {
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return false, err
 	}
+	req.Time = tm
+
+	return t.ValidateWith(passCode, req)
+}
 
-	newCounters := util.CalculateCounters(counter, t.Skew)
-	hObj := hotp.HOtp{Digits: t.Digits, Algorithm: t.Algorithm, Secret: t.Secret, Pattern: t.Pattern}
+// ValidateWith verify dynamic password against the time step containing req.Time
+func (t *TOtp) ValidateWith(passCode string, req abstract.Request) (bool, error) {
+	res := t.VerifyWith(passCode, req)
 
-	for _, c := range newCounters {
-		isValid, err := hObj.ValidateForCounter(passCode, c, pin)
+	return res.Valid, res.Err
+}
+
+// Verify verify dynamic password and report the matched time step, drift and failure reason
+func (t *TOtp) Verify(passCode string, counters ...any) abstract.VerifyResult {
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return abstract.VerifyResult{Reason: enum.ReasonError, Err: err}
+	}
+
+	return t.VerifyWith(passCode, req)
+}
+
+// VerifyAt verify dynamic password against the time step containing tm
+func (t *TOtp) VerifyAt(passCode string, tm time.Time, counters ...any) abstract.VerifyResult {
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return abstract.VerifyResult{Reason: enum.ReasonError, Err: err}
+	}
+	req.Time = tm
+
+	return t.VerifyWith(passCode, req)
+}
+
+// VerifyWith verify dynamic password against the time step containing req.Time.
+// Drift is the matched step minus the current step, negative for slow devices.
+func (t *TOtp) VerifyWith(passCode string, req abstract.Request) abstract.VerifyResult {
+	counter := t.counterAt(t.timeOf(req))
+
+	if t.Pattern == enum.Mobile && req.PIN == "" {
+		return abstract.VerifyResult{Reason: enum.ReasonMissingPIN, Err: common.ErrMissingPIN}
+	}
+	if utf8.RuneCountInString(strings.TrimSpace(passCode)) != t.Digits.Length() {
+		return abstract.VerifyResult{Reason: enum.ReasonWrongLength, Err: fmt.Errorf("validation failed: %w", common.ErrWrongLength)}
+	}
+
+	key := util.StoreKey(t.Issuer, t.AccountName, t.Secret)
+	var d int64
+	if t.DriftStore != nil {
+		stored, err := t.DriftStore.Load(key)
 		if err != nil {
-			return false, fmt.Errorf("validation failed: %w", err)
+			return abstract.VerifyResult{Reason: enum.ReasonError, Err: fmt.Errorf("load drift failed: %w", err)}
 		}
-		if isValid {
-			return true, nil
+		d = drift.Clamp(stored, t.MaxDrift)
+	}
+
+	hObj := hotp.HOtp{Digits: t.Digits, Algorithm: t.Algorithm, Secret: t.Secret, Pattern: t.Pattern, Registry: t.Registry}
+
+	for c := range util.WindowCounters(counter, d, t.Skew) {
+		isValid, err := hObj.ValidateForCounter(passCode, c, req.Args()...)
+		if err != nil {
+			return abstract.VerifyResult{Reason: enum.ReasonError, Err: fmt.Errorf("validation failed: %w", err)}
+		}
+		if !isValid {
+			continue
+		}
+
+		res := abstract.VerifyResult{Counter: c, Drift: int64(c) - counter}
+		if t.ReplayStore != nil {
+			ttl := time.Duration(2*t.Skew+2) * time.Duration(t.Period) * time.Second
+			if err = replay.Check(t.ReplayStore, key, c, ttl); err != nil {
+				res.Reason, res.Err = enum.ReasonError, err
+				if errors.Is(err, replay.ErrReplay) {
+					res.Reason = enum.ReasonReplay
+				}
+				return res
+			}
+		}
+		if t.DriftStore != nil {
+			if err = t.DriftStore.Save(key, drift.Clamp(res.Drift, t.MaxDrift)); err != nil {
+				return abstract.VerifyResult{Counter: c, Drift: res.Drift, Reason: enum.ReasonError, Err: fmt.Errorf("save drift failed: %w", err)}
+			}
 		}
+		res.Valid = true
+		return res
 	}
-	return false, errors.New("invalid dynamic code")
+	return abstract.VerifyResult{Reason: enum.ReasonMismatch}
+}
+
+// timeOf the time of req, the current time when unset
+func (t *TOtp) timeOf(req abstract.Request) time.Time {
+	if req.Time.IsZero() {
+		return t.Clock.Now()
+	}
+	return req.Time
+}
+
+// counterAt returns the time step counter of tm
+func (t *TOtp) counterAt(tm time.Time) int64 {
+	return int64(math.Floor(float64(tm.UTC().Unix()) / float64(t.Period)))
 }
 
 // GenerateKey new key
 func (t *TOtp) GenerateKey() (string, error) {
 	if t.Issuer == "" || t.AccountName == "" {
-		return "", errors.New("lacking necessary account information")
+		return "", common.ErrMissingAccount
 	}
 
 	val := url.Values{}
@@ -171,8 +328,11 @@ func (t *TOtp) GenerateKey() (string, error) {
 	val.Set("period", strconv.FormatUint(uint64(t.Period), 10))
 	val.Set("algorithm", t.Algorithm.String())
 	val.Set("digits", t.Digits.String())
+	if t.Host != "totp" {
+		val.Set("type", "totp")
+	}
 
-	u := url.URL{Scheme: "otpauth", Host: t.Host, Path: "/" + url.PathEscape(t.Issuer+":"+t.AccountName), RawQuery: util.EncodeQuery(val)}
+	u := url.URL{Scheme: "otpauth", Host: t.Host, Path: "/" + t.Issuer + ":" + t.AccountName, RawQuery: util.EncodeQuery(val)}
 
 	return util.NewKeyFromUrl(u.String())
 }
@@ -0,0 +1,272 @@
+package totp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"github.com/dhlanshan/otp/drift"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/pattern"
+	"github.com/dhlanshan/otp/replay"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTOtp the RFC 6238 SHA1 test configuration with 8 digits
+func newTOtp(t *testing.T, opts ...Option) *TOtp {
+	t.Helper()
+	opts = append([]Option{WithSecret([]byte("12345678901234567890")), WithDigits(enum.DigitEight)}, opts...)
+	obj, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+// codeAt the code of obj at tm
+func codeAt(t *testing.T, obj *TOtp, tm time.Time) string {
+	t.Helper()
+	codes, err := obj.GenerateCodeWith(abstract.Request{Time: tm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codes[0]
+}
+
+func TestValidateReplay(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1111111109, 0))
+	obj := newTOtp(t, WithAccountName("alice"), WithSkew(1), WithClock(clock), WithReplayStore(replay.NewMemoryStore()))
+	if ok, err := obj.Validate("07081804"); !ok || err != nil {
+		t.Fatalf("first use = %v, %v", ok, err)
+	}
+	if ok, err := obj.Validate("07081804"); ok || !errors.Is(err, replay.ErrReplay) {
+		t.Fatalf("reuse = %v, %v; want ErrReplay", ok, err)
+	}
+	if res := obj.Verify("07081804"); res.Reason != enum.ReasonReplay {
+		t.Fatalf("reuse = %+v", res)
+	}
+}
+
+func TestReplaySecrets(t *testing.T) {
+	clock := NewFakeClock(time.Unix(59, 0))
+	store := replay.NewMemoryStore()
+	a := newTOtp(t, WithClock(clock), WithReplayStore(store))
+	b := newTOtp(t, WithSecret([]byte("abcdefghijklmnopqrst")), WithClock(clock), WithReplayStore(store))
+	codeB := codeAt(t, b, clock.Now())
+	// both use the default issuer and account name, but not the same secret
+	if ok, _ := a.Validate("94287082"); !ok {
+		t.Fatal("expected the code of the first secret to be accepted")
+	}
+	if ok, _ := b.Validate(codeB); !ok {
+		t.Fatal("expected the code of the second secret to be accepted")
+	}
+	if ok, _ := b.Validate(codeB); ok {
+		t.Fatal("expected reuse to be rejected")
+	}
+}
+
+func TestVerifyWithDriftStore(t *testing.T) {
+	// 14050471 is the RFC 6238 SHA1 code of step 37037037
+	clock := NewFakeClock(time.Unix(1111111111+30, 0))
+	store := drift.NewMemoryStore()
+	obj := newTOtp(t, WithAccountName("alice"), WithSkew(1), WithClock(clock), WithDriftStore(store, 5))
+	if res := obj.Verify("14050471"); !res.Valid || res.Drift != -1 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	clock.Advance(30 * time.Second)
+	plain := newTOtp(t, WithAccountName("alice"), WithSkew(1), WithClock(clock))
+	if ok, _ := plain.Validate("14050471"); ok {
+		t.Fatal("expected a two step drift to be rejected without drift tracking")
+	}
+	if res := obj.Verify("14050471"); !res.Valid || res.Drift != -2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	key := util.StoreKey(common.DefaultIssuer, "alice", obj.Secret)
+	if d, _ := store.Load(key); d != -2 {
+		t.Fatalf("stored drift = %d; want -2", d)
+	}
+
+	// another secret of the same account does not inherit the drift
+	other := newTOtp(t, WithSecret([]byte("abcdefghijklmnopqrst")), WithAccountName("alice"), WithSkew(1), WithClock(clock), WithDriftStore(store, 5))
+	if ok, _ := other.Validate(codeAt(t, other, clock.Now().Add(-60*time.Second))); ok {
+		t.Fatal("expected the drift of another secret not to apply")
+	}
+	if d, _ := store.Load(key); d != -2 {
+		t.Fatalf("stored drift = %d after another secret; want -2", d)
+	}
+}
+
+func TestDriftCorrectedClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1111111111, 0))
+	store := drift.NewMemoryStore()
+	obj := newTOtp(t, WithAccountName("alice"), WithSkew(1), WithClock(clock), WithDriftStore(store, 5))
+	key := util.StoreKey(common.DefaultIssuer, "alice", obj.Secret)
+	if err := store.Save(key, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// the device clock was fast by three steps and is now correct
+	if res := obj.Verify(codeAt(t, obj, clock.Now())); !res.Valid || res.Drift != 0 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if d, _ := store.Load(key); d != 0 {
+		t.Fatalf("stored drift = %d; want 0", d)
+	}
+	if err := store.Save(key, 3); err != nil {
+		t.Fatal(err)
+	}
+	if res := obj.Verify(codeAt(t, obj, clock.Now().Add(120*time.Second))); !res.Valid || res.Drift != 4 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func TestCodeInfo(t *testing.T) {
+	clock := NewFakeClock(time.Unix(1111111109, 500_000_000))
+	obj := newTOtp(t, WithClock(clock))
+	info, err := obj.GenerateCodeInfoWith(abstract.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1111111109 is in step 37037036, [1111111080, 1111111110)
+	if info.Code != "07081804" || info.Counter != 37037036 ||
+		info.Start.Unix() != 1111111080 || info.End.Unix() != 1111111110 || info.Remaining != 500*time.Millisecond {
+		t.Fatalf("info = %+v", info)
+	}
+
+	obj = newTOtp(t, WithPeriod(60))
+	at := time.Unix(125, 0)
+	if got := obj.NextRotation(at); got.Unix() != 180 {
+		t.Errorf("NextRotation = %d; want 180", got.Unix())
+	}
+	if got := obj.StepStart(at); got.Unix() != 120 {
+		t.Errorf("StepStart = %d; want 120", got.Unix())
+	}
+	if got := obj.Remaining(at); got != 55*time.Second {
+		t.Errorf("Remaining = %s; want 55s", got)
+	}
+	info, err = obj.GenerateCodeInfoWith(abstract.Request{Time: time.Unix(180, 0)})
+	if err != nil || info.Counter != 3 || info.Remaining != time.Minute {
+		t.Fatalf("step boundary = %+v, %v", info, err)
+	}
+}
+
+func TestGenerateRange(t *testing.T) {
+	obj := newTOtp(t)
+	// RFC 6238 Appendix B, 1111111109 and 1111111111 straddle a step boundary
+	infos, err := obj.GenerateRange(time.Unix(1111111109, 0), time.Unix(1111111111, 0), abstract.Request{})
+	if err != nil || len(infos) != 2 {
+		t.Fatalf("GenerateRange = %+v, %v", infos, err)
+	}
+	if infos[0].Code != "07081804" || infos[1].Code != "14050471" || infos[1].Counter != infos[0].Counter+1 || !infos[1].Start.Equal(infos[0].End) {
+		t.Fatalf("GenerateRange = %+v", infos)
+	}
+	if infos[0].Remaining != time.Second || infos[1].Remaining != 30*time.Second {
+		t.Fatalf("remaining = %s, %s", infos[0].Remaining, infos[1].Remaining)
+	}
+
+	// A range ending on a boundary includes the step starting there
+	infos, err = obj.GenerateRange(time.Unix(0, 0), time.Unix(90, 0), abstract.Request{})
+	if err != nil || len(infos) != 4 || infos[3].Counter != 3 {
+		t.Fatalf("boundary range = %d codes, %v", len(infos), err)
+	}
+	seq, err := obj.Range(time.Unix(0, 0), time.Unix(3000, 0), abstract.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, err := range seq {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n++; n == 5 {
+			break
+		}
+	}
+	if n != 5 {
+		t.Fatalf("early break = %d", n)
+	}
+	if _, err = obj.GenerateRange(time.Unix(60, 0), time.Unix(0, 0), abstract.Request{}); err == nil {
+		t.Fatal("reversed range accepted")
+	}
+	if _, err = obj.GenerateRange(time.Unix(0, 0), time.Unix(1<<62, 0), abstract.Request{}); !errors.Is(err, common.ErrInvalidParameter) {
+		t.Fatalf("unbounded range = %v; want ErrInvalidParameter", err)
+	}
+	if infos, err = obj.GenerateRange(time.Unix(0, 0), time.Unix(30*(MaxRangeSteps-1), 0), abstract.Request{}); err != nil || len(infos) != MaxRangeSteps {
+		t.Fatalf("largest range = %d codes, %v", len(infos), err)
+	}
+
+	// a code failing mid-range is reported, not silently dropped
+	reg := pattern.NewRegistry()
+	if err = reg.Register("limited", limitedPattern{max: 2}); err != nil {
+		t.Fatal(err)
+	}
+	limited := newTOtp(t, WithPattern("limited"), WithRegistry(reg))
+	if infos, err = limited.GenerateRange(time.Unix(0, 0), time.Unix(120, 0), abstract.Request{}); !errors.Is(err, errLimited) || infos != nil {
+		t.Fatalf("failing range = %+v, %v", infos, err)
+	}
+
+	// the same sequence can be iterated concurrently
+	seq, _ = obj.Range(time.Unix(0, 0), time.Unix(300, 0), abstract.Request{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, err := range seq {
+				if err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+var errLimited = errors.New("counter out of range")
+
+// limitedPattern the standard pattern, failing from counter max on
+type limitedPattern struct {
+	max uint64
+}
+
+func (p limitedPattern) CounterFun(buf []byte, _ ...string) ([]byte, error) {
+	if binary.BigEndian.Uint64(buf) >= p.max {
+		return nil, errLimited
+	}
+	return buf, nil
+}
+
+func (limitedPattern) CalculationFun(value int64, _ int, digits enum.DigitEnum) string {
+	return digits.Format(value % 1000000)
+}
+
+func TestStream(t *testing.T) {
+	obj := newTOtp(t, WithPeriod(1))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ch, err := obj.Stream(ctx, abstract.Request{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := <-ch, <-ch
+	if second.Counter != first.Counter+1 || !second.Start.Equal(first.End) || ctx.Err() != nil {
+		t.Fatalf("stream = %+v, %+v", first, second)
+	}
+	want, _ := obj.GenerateCodeInfoWith(abstract.Request{Time: second.Start})
+	if second.Code != want.Code {
+		t.Fatalf("stream code = %s; want %s", second.Code, want.Code)
+	}
+	cancel()
+	for range ch {
+	}
+
+	mobile := newTOtp(t, WithPattern(enum.Mobile))
+	if _, err = mobile.Upcoming(context.Background(), abstract.Request{}); !errors.Is(err, common.ErrMissingPIN) {
+		t.Fatalf("missing pin = %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/dhlanshan/otp/drift"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/hotp"
+	"github.com/dhlanshan/otp/internal/abstract"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/totp"
+	"hash"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Verifier validates the codes of one secret with the policies of its configuration.
+// It is built once and reuses the HMAC key schedule and buffers across calls, so the
+// standard pattern verifies without allocating. A Verifier is safe for concurrent use;
+// it keeps the pattern registered when it was built.
+type Verifier struct {
+	otpType     TypeEnum
+	storeKey    string
+	digits      enum.DigitEnum
+	patternName enum.PatternEnum
+	pattern     abstract.Pattern
+	standard    bool
+	period      uint
+	skew        uint
+	lookAhead   uint
+	maxDrift    uint
+	clock       abstract.Clock
+	replayStore replay.Store
+	driftStore  drift.Store
+	macs        sync.Pool
+}
+
+// macState a reusable HMAC with its message and sum buffers
+type macState struct {
+	mac hash.Hash
+	msg [8]byte
+	sum []byte
+}
+
+// NewVerifier build the verifier of cmd
+func NewVerifier(cmd *CreateOtpCmd) (*Verifier, error) {
+	obj, err := NewOtpInstance(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Verifier{otpType: cmd.OtpType}
+	var secret []byte
+	var algorithm enum.AlgorithmEnum
+	switch o := obj.(type) {
+	case *hotp.HOtp:
+		v.storeKey = util.StoreKey(o.Issuer, o.AccountName, o.Secret)
+		v.digits, v.patternName, v.lookAhead, v.replayStore = o.Digits, o.Pattern, o.LookAhead, o.ReplayStore
+		v.pattern, _ = o.Registry.Lookup(o.Pattern)
+		secret, algorithm = o.Secret, o.Algorithm
+	case *totp.TOtp:
+		v.storeKey = util.StoreKey(o.Issuer, o.AccountName, o.Secret)
+		v.digits, v.patternName, v.period, v.skew = o.Digits, o.Pattern, o.Period, o.Skew
+		v.clock, v.replayStore, v.driftStore, v.maxDrift = o.Clock, o.ReplayStore, o.DriftStore, o.MaxDrift
+		v.pattern, _ = o.Registry.Lookup(o.Pattern)
+		secret, algorithm = o.Secret, o.Algorithm
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnsupportedOtpType, obj)
+	}
+	v.standard = v.patternName == enum.Standard
+
+	secret = append([]byte(nil), secret...)
+	v.macs.New = func() any {
+		mac := hmac.New(algorithm.Hash, secret)
+		return &macState{mac: mac, sum: make([]byte, 0, mac.Size())}
+	}
+
+	return v, nil
+}
+
+// Validate verify dynamic password, see Verify
+func (v *Verifier) Validate(passCode string, counters ...any) (bool, error) {
+	res := v.Verify(passCode, counters...)
+
+	return res.Valid, res.Err
+}
+
+// ValidateWith verify dynamic password with typed parameters, see VerifyWith
+func (v *Verifier) ValidateWith(passCode string, req Request) (bool, error) {
+	res := v.VerifyWith(passCode, req)
+
+	return res.Valid, res.Err
+}
+
+// Verify verify dynamic password, the parameters are those of the HOTP or TOTP Verify
+func (v *Verifier) Verify(passCode string, counters ...any) VerifyResult {
+	req, err := util.ParseRequest(counters)
+	if err != nil {
+		return VerifyResult{Reason: enum.ReasonError, Err: err}
+	}
+	if v.otpType == HOTP && req.Counter == 0 {
+		return VerifyResult{Reason: enum.ReasonMissingCounter, Err: common.ErrMissingCounter}
+	}
+
+	return v.VerifyWith(passCode, req)
+}
+
+// VerifyWith verify dynamic password with typed parameters, with the same window, replay and
+// drift handling as the HOtp or TOtp built from the configuration
+func (v *Verifier) VerifyWith(passCode string, req Request) VerifyResult {
+	if v.patternName == enum.Mobile && req.PIN == "" {
+		return VerifyResult{Reason: enum.ReasonMissingPIN, Err: common.ErrMissingPIN}
+	}
+	passCode = strings.TrimSpace(passCode)
+	if utf8.RuneCountInString(passCode) != v.digits.Length() {
+		return VerifyResult{Reason: enum.ReasonWrongLength, Err: common.ErrWrongLength}
+	}
+
+	var args []string
+	if !v.standard {
+		args = req.Args()
+	}
+	want, numeric := v.parse(passCode)
+
+	if v.otpType == HOTP {
+		for i := uint64(0); i <= uint64(v.lookAhead); i++ {
+			c := req.Counter + i
+			if c < req.Counter {
+				break
+			}
+			ok, err := v.match(passCode, want, numeric, c, args)
+			if err != nil {
+				return VerifyResult{Reason: enum.ReasonError, Err: err}
+			}
+			if ok {
+				return v.accept(VerifyResult{Counter: c, Drift: int64(i)}, 0)
+			}
+		}
+		return VerifyResult{Reason: enum.ReasonMismatch}
+	}
+
+	tm := req.Time
+	if tm.IsZero() {
+		tm = v.clock.Now()
+	}
+	counter := int64(math.Floor(float64(tm.UTC().Unix()) / float64(v.period)))
+	var d int64
+	if v.driftStore != nil {
+		stored, err := v.driftStore.Load(v.storeKey)
+		if err != nil {
+			return VerifyResult{Reason: enum.ReasonError, Err: fmt.Errorf("load drift failed: %w", err)}
+		}
+		d = drift.Clamp(stored, v.maxDrift)
+	}
+
+	ttl := time.Duration(2*v.skew+2) * time.Duration(v.period) * time.Second
+	for c := range util.WindowCounters(counter, d, v.skew) {
+		ok, err := v.match(passCode, want, numeric, c, args)
+		if err != nil {
+			return VerifyResult{Reason: enum.ReasonError, Err: fmt.Errorf("validation failed: %w", err)}
+		}
+		if ok {
+			return v.accept(VerifyResult{Counter: c, Drift: int64(c) - counter}, ttl)
+		}
+	}
+
+	return VerifyResult{Reason: enum.ReasonMismatch}
+}
+
+// accept apply the replay and drift stores to a matched code
+func (v *Verifier) accept(res VerifyResult, ttl time.Duration) VerifyResult {
+	if v.replayStore != nil {
+		if err := replay.Check(v.replayStore, v.storeKey, res.Counter, ttl); err != nil {
+			res.Reason, res.Err = enum.ReasonError, err
+			if errors.Is(err, replay.ErrReplay) {
+				res.Reason = enum.ReasonReplay
+			}
+			return res
+		}
+	}
+	if v.driftStore != nil {
+		if err := v.driftStore.Save(v.storeKey, drift.Clamp(res.Drift, v.maxDrift)); err != nil {
+			res.Reason, res.Err = enum.ReasonError, fmt.Errorf("save drift failed: %w", err)
+			return res
+		}
+	}
+	res.Valid = true
+
+	return res
+}
+
+// parse the decimal value of a standard pattern code, numeric is false for other codes
+func (v *Verifier) parse(passCode string) (value uint64, numeric bool) {
+	if !v.standard {
+		return 0, false
+	}
+	for _, r := range passCode {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+		value = value*10 + uint64(r-'0')
+	}
+
+	return value, true
+}
+
+// match whether passCode is the code of counter
+func (v *Verifier) match(passCode string, want uint64, numeric bool, counter uint64, args []string) (bool, error) {
+	if v.standard && !numeric {
+		return false, nil
+	}
+
+	st := v.macs.Get().(*macState)
+	defer v.macs.Put(st)
+
+	binary.BigEndian.PutUint64(st.msg[:], counter)
+	msg := st.msg[:]
+	if !v.standard {
+		var err error
+		if msg, err = v.pattern.CounterFun(msg, args...); err != nil {
+			return false, err
+		}
+	}
+	st.mac.Reset()
+	st.mac.Write(msg)
+	st.sum = st.mac.Sum(st.sum[:0])
+
+	value := util.DynamicTruncate(st.sum)
+
+	if v.standard {
+		got := uint64(value) % uint64(math.Pow10(v.digits.Length()))
+		eq := subtle.ConstantTimeEq(int32(got>>32), int32(want>>32)) & subtle.ConstantTimeEq(int32(got), int32(want))
+		return eq == 1, nil
+	}
+	code := v.pattern.CalculationFun(value, v.digits.Length(), v.digits)
+
+	return subtle.ConstantTimeCompare([]byte(code), []byte(passCode)) == 1, nil
+}
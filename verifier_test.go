@@ -0,0 +1,170 @@
+package otp
+
+import (
+	"errors"
+	"github.com/dhlanshan/otp/drift"
+	"github.com/dhlanshan/otp/enum"
+	"github.com/dhlanshan/otp/internal/common"
+	"github.com/dhlanshan/otp/internal/util"
+	"github.com/dhlanshan/otp/replay"
+	"github.com/dhlanshan/otp/totp"
+	"testing"
+	"time"
+)
+
+func TestVerifier(t *testing.T) {
+	clock := totp.NewFakeClock(time.Unix(1111111109, 0))
+	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8, Skew: 1, Clock: clock}
+	v, err := NewVerifier(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res := v.Verify("07081804"); !res.Valid || res.Drift != 0 {
+		t.Fatalf("current step = %+v", res)
+	}
+	clock.Advance(30 * time.Second)
+	if res := v.Verify("07081804"); !res.Valid || res.Drift != -1 {
+		t.Fatalf("previous step = %+v", res)
+	}
+	if res := v.Verify("07081805"); res.Valid || res.Reason != enum.ReasonMismatch {
+		t.Fatalf("wrong code = %+v", res)
+	}
+	if res := v.Verify("0708180x"); res.Valid || res.Reason != enum.ReasonMismatch {
+		t.Fatalf("non-numeric code = %+v", res)
+	}
+	if res := v.Verify("0708180"); res.Reason != enum.ReasonWrongLength || !errors.Is(res.Err, ErrWrongLength) {
+		t.Fatalf("short code = %+v", res)
+	}
+
+	// The verifier agrees with the HOtp it replaces, including look-ahead and replay
+	hCmd := &CreateOtpCmd{OtpType: HOTP, Secret: "12345678901234567890", LookAhead: 3, ReplayStore: replay.NewMemoryStore()}
+	hv, err := NewVerifier(hCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = hv.Validate("755224"); !errors.Is(err, ErrMissingCounter) {
+		t.Fatalf("no counter = %v", err)
+	}
+	if res := hv.VerifyWith("969429", Request{Counter: 1}); !res.Valid || res.Counter != 3 || res.Drift != 2 {
+		t.Fatalf("look-ahead = %+v", res)
+	}
+	if res := hv.VerifyWith("969429", Request{Counter: 1}); res.Reason != enum.ReasonReplay {
+		t.Fatalf("replayed = %+v", res)
+	}
+	// it shares replay records with the HOtp of the same secret only
+	if ValidateWith(hCmd, "969429", Request{Counter: 1}) {
+		t.Fatal("code accepted by the verifier reused through ValidateWith")
+	}
+	other := &CreateOtpCmd{OtpType: HOTP, Secret: "abcdefghijklmnopqrst", ReplayStore: hCmd.ReplayStore}
+	ov, err := NewVerifier(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherCode, _ := GenerateCodeWith(other, Request{Counter: 3})
+	if res := ov.VerifyWith(otherCode, Request{Counter: 3}); !res.Valid {
+		t.Fatalf("other secret = %+v", res)
+	}
+
+	// Non-standard patterns use the registered implementation
+	sCmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Pattern: enum.Steam}
+	at := time.Unix(1234567890, 0)
+	code, err := GenerateCodeAt(sCmd, at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sv, err := NewVerifier(sCmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := sv.ValidateWith(code, Request{Time: at}); !ok || err != nil {
+		t.Fatalf("steam code %s = %v, %v", code, ok, err)
+	}
+
+	// Concurrent use
+	done := make(chan bool)
+	for i := 0; i < 8; i++ {
+		go func() {
+			ok := true
+			for j := 0; j < 100; j++ {
+				ok = ok && v.VerifyWith("07081804", Request{Time: time.Unix(1111111109, 0)}).Valid
+			}
+			done <- ok
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if !<-done {
+			t.Fatal("concurrent verification failed")
+		}
+	}
+}
+
+func TestVerifierCorrectedClock(t *testing.T) {
+	clock := totp.NewFakeClock(time.Unix(1111111111, 0))
+	store := drift.NewMemoryStore()
+	cmd := &CreateOtpCmd{OtpType: TOTP, AccountName: "alice", Secret: "12345678901234567890", Skew: 1, MaxDrift: 5, Clock: clock, DriftStore: store}
+	key := util.StoreKey(common.DefaultIssuer, "alice", []byte(cmd.Secret))
+	if err := store.Save(key, 3); err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewVerifier(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the device clock was fast by three steps and is now correct
+	code, _ := GenerateCodeWith(cmd, Request{Time: clock.Now()})
+	if res := v.Verify(code); !res.Valid || res.Drift != 0 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if d, _ := store.Load(key); d != 0 {
+		t.Fatalf("stored drift = %d; want 0", d)
+	}
+	if err = store.Save(key, 3); err != nil {
+		t.Fatal(err)
+	}
+	code, _ = GenerateCodeWith(cmd, Request{Time: clock.Now().Add(120 * time.Second)})
+	if res := v.Verify(code); !res.Valid || res.Drift != 4 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+}
+
+func BenchmarkVerifier(b *testing.B) {
+	v, err := NewVerifier(&CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8, Skew: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+	req := Request{Time: time.Unix(1111111109, 0)}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		// The worst case, every step of the window is tried
+		if v.VerifyWith("00000000", req).Valid {
+			b.Fatal("unexpected match")
+		}
+	}
+}
+
+func BenchmarkVerifierParallel(b *testing.B) {
+	v, err := NewVerifier(&CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8, Skew: 1})
+	if err != nil {
+		b.Fatal(err)
+	}
+	req := Request{Time: time.Unix(1111111109, 0)}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if !v.VerifyWith("07081804", req).Valid {
+				b.Fatal("rejected")
+			}
+		}
+	})
+}
+
+func BenchmarkValidateWith(b *testing.B) {
+	cmd := &CreateOtpCmd{OtpType: TOTP, Secret: "12345678901234567890", Digits: 8, Skew: 1}
+	req := Request{Time: time.Unix(1111111109, 0)}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ValidateWith(cmd, "00000000", req)
+	}
+}